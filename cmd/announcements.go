@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	announcementTimeLayout = "2006-01-02 15:04"
+
+	announcementStatusPending   = "pending"
+	announcementStatusSent      = "sent"
+	announcementStatusFailed    = "failed"
+	announcementStatusMissed    = "missed"
+	announcementStatusCancelled = "cancelled"
+)
+
+// Announcement is a one-off post to the group that an admin scheduled with /schedule
+type Announcement struct {
+	ID     int64     `json:"id"`
+	SendAt time.Time `json:"send_at"`
+	Post
+	Author    User      `json:"author"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnnouncementDraft is a /schedule waiting for the admin to send the post
+type AnnouncementDraft struct {
+	SendAt    time.Time `json:"send_at"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func isAdminChatMemberStatus(status string) bool {
+	return isAdminStatus(status) || status == "member"
+}
+
+// isAdminUser reports whether userID is a member of the admin chat, they may manage the bot in private chat
+func (app *App) isAdminUser(userID int64) bool {
+	if app.config.AdminChatID == 0 {
+		return false
+	}
+	member, err := app.telegram.getChatMember(app.config.AdminChatID, userID)
+	if err != nil {
+		slog.Error("Error getting admin chat member", "user_id", userID, "error", err)
+		return false
+	}
+	return isAdminChatMemberStatus(member.Status)
+}
+
+// adminPrivateOnly restricts a command to private chats with members of the admin chat
+func (app *App) adminPrivateOnly(handler CommandHandler) CommandHandler {
+	return func(message *Message, args string) {
+		if message.Chat.Type == "private" && app.isAdminUser(message.From.ID) {
+			handler(message, args)
+		}
+	}
+}
+
+func formatAnnouncementTime(t time.Time, location *time.Location) string {
+	return t.In(location).Format("02.01.2006 15:04")
+}
+
+func (app *App) announcementLocation() *time.Location {
+	location, err := time.LoadLocation(app.config.Announcements.Timezone)
+	if err != nil {
+		// Config validation rejects unknown timezones
+		return time.UTC
+	}
+	return location
+}
+
+func (app *App) pendingAnnouncements() []Announcement {
+	var pending []Announcement
+	app.store.view(func(data *storeData) {
+		for _, announcement := range data.Announcements {
+			if announcement.Status == announcementStatusPending {
+				pending = append(pending, *announcement)
+			}
+		}
+	})
+	slices.SortStableFunc(pending, func(a, b Announcement) int { return a.SendAt.Compare(b.SendAt) })
+	return pending
+}
+
+func (app *App) findPendingAnnouncement(args string) (Announcement, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(args, "№"), 10, 64)
+	if err != nil {
+		return Announcement{}, false
+	}
+	pending := app.pendingAnnouncements()
+	index := slices.IndexFunc(pending, func(announcement Announcement) bool { return announcement.ID == id })
+	if index < 0 {
+		return Announcement{}, false
+	}
+	return pending[index], true
+}
+
+func (app *App) handleScheduleCommand(message *Message, args string) {
+	location := app.announcementLocation()
+	sendAt, err := time.ParseInLocation(announcementTimeLayout, args, location)
+	if err != nil {
+		app.replyText(message, fmt.Sprintf("Укажите дату и время публикации (%s): /schedule 2026-11-01 10:00", location))
+		return
+	}
+	if !sendAt.After(time.Now()) {
+		app.replyText(message, "Это время уже прошло.")
+		return
+	}
+
+	err = app.store.update(func(data *storeData) {
+		data.AnnouncementDrafts[message.From.ID] = &AnnouncementDraft{SendAt: sendAt, StartedAt: time.Now()}
+		// The next post goes to the group, not to the subscribers
+		delete(data.BroadcastDrafts, message.From.ID)
+	})
+	if err != nil {
+		slog.Error("Error saving announcement draft", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось начать публикацию, попробуйте ещё раз.")
+		return
+	}
+	app.replyText(message, fmt.Sprintf(
+		"Публикация на %s. Пришлите текст или фото с подписью. Кнопки добавляются строками в конце:\nТекст кнопки | https://example.com\n\nПередумали — /unschedule",
+		formatAnnouncementTime(sendAt, location),
+	))
+}
+
+func (app *App) isAnnouncementMessage(message *Message) bool {
+	if message == nil || message.Chat.Type != "private" || app.config.AdminChatID == 0 || isCommand(message) {
+		return false
+	}
+	var ok bool
+	app.store.view(func(data *storeData) {
+		draft, found := data.AnnouncementDrafts[message.From.ID]
+		ok = found && !isPostDraftExpired(draft.StartedAt, time.Now())
+	})
+	return ok
+}
+
+func (app *App) handleAnnouncementMessage(message *Message) {
+	var sendAt time.Time
+	app.store.view(func(data *storeData) {
+		if draft, ok := data.AnnouncementDrafts[message.From.ID]; ok {
+			sendAt = draft.SendAt
+		}
+	})
+
+	post, ok := app.composePost(message)
+	if !ok {
+		return
+	}
+	announcement := Announcement{
+		SendAt:    sendAt,
+		Post:      post,
+		Author:    message.From,
+		Status:    announcementStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	err := app.store.update(func(data *storeData) {
+		data.LastAnnouncementID++
+		announcement.ID = data.LastAnnouncementID
+		data.Announcements = append(data.Announcements, &announcement)
+		delete(data.AnnouncementDrafts, message.From.ID)
+	})
+	if err != nil {
+		slog.Error("Error saving announcement", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось сохранить публикацию, попробуйте ещё раз.")
+		return
+	}
+	slog.Info("Announcement scheduled", "id", announcement.ID, "send_at", announcement.SendAt, "author_id", message.From.ID)
+	app.replyText(message, fmt.Sprintf(
+		"Публикация №%d запланирована на %s, выше — как она будет выглядеть.\nСписок: /scheduled, отменить: /unschedule %d",
+		announcement.ID, formatAnnouncementTime(announcement.SendAt, app.announcementLocation()), announcement.ID,
+	))
+}
+
+func createAnnouncementsList(announcements []Announcement, location *time.Location) string {
+	if len(announcements) == 0 {
+		return "Запланированных публикаций нет. Запланировать: /schedule 2026-11-01 10:00"
+	}
+	lines := []string{"Запланированные публикации:"}
+	for _, announcement := range announcements {
+		lines = append(lines, fmt.Sprintf("№%d — %s — %s", announcement.ID, formatAnnouncementTime(announcement.SendAt, location), announcement.title()))
+	}
+	lines = append(lines, "", "Посмотреть: /preview <номер>, отменить: /unschedule <номер>")
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) handleScheduledCommand(message *Message, args string) {
+	app.replyText(message, createAnnouncementsList(app.pendingAnnouncements(), app.announcementLocation()))
+}
+
+func (app *App) handlePreviewCommand(message *Message, args string) {
+	announcement, ok := app.findPendingAnnouncement(args)
+	if !ok {
+		app.replyText(message, "Публикация не найдена. Список: /scheduled")
+		return
+	}
+	if err := app.sendPost(&announcement.Post, message.Chat.ID, 0); err != nil {
+		slog.Error("Error sending announcement preview", "id", announcement.ID, "error", err)
+		app.replyText(message, "Не получилось показать публикацию.")
+	}
+}
+
+// handleUnscheduleCommand cancels a pending announcement, without a number it drops the one being composed
+func (app *App) handleUnscheduleCommand(message *Message, args string) {
+	if args == "" {
+		err := app.store.update(func(data *storeData) {
+			delete(data.AnnouncementDrafts, message.From.ID)
+		})
+		if err != nil {
+			slog.Error("Error removing announcement draft", "user_id", message.From.ID, "error", err)
+		}
+		app.replyText(message, "Публикация отменена.")
+		return
+	}
+
+	announcement, ok := app.findPendingAnnouncement(args)
+	if !ok {
+		app.replyText(message, "Публикация не найдена. Список: /scheduled")
+		return
+	}
+	err := app.store.update(func(data *storeData) {
+		for _, stored := range data.Announcements {
+			if stored.ID == announcement.ID && stored.Status == announcementStatusPending {
+				stored.Status = announcementStatusCancelled
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error cancelling announcement", "id", announcement.ID, "error", err)
+		app.replyText(message, "Не получилось отменить публикацию.")
+		return
+	}
+	slog.Info("Announcement cancelled", "id", announcement.ID, "user_id", message.From.ID)
+	app.replyText(message, fmt.Sprintf("Публикация №%d отменена.", announcement.ID))
+}
+
+func (app *App) setAnnouncementStatus(id int64, status string) {
+	err := app.store.update(func(data *storeData) {
+		for _, announcement := range data.Announcements {
+			if announcement.ID == id {
+				announcement.Status = status
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving announcement status", "id", id, "status", status, "error", err)
+	}
+}
+
+// runAnnouncements posts the announcements that are due. Like scheduled posts they are marked
+// before sending, so a restart never posts them twice.
+func (app *App) runAnnouncements(now time.Time) {
+	var due []Announcement
+	for _, announcement := range app.pendingAnnouncements() {
+		if !announcement.SendAt.After(now) {
+			due = append(due, announcement)
+		}
+	}
+
+	for _, announcement := range due {
+		if now.Sub(announcement.SendAt) > scheduleMissedLimit {
+			app.setAnnouncementStatus(announcement.ID, announcementStatusMissed)
+			slog.Warn("Skipping missed announcement", "id", announcement.ID, "send_at", announcement.SendAt)
+			app.alertAdmins(fmt.Sprintf("Публикация №%d не отправлена вовремя (%s) и пропущена.", announcement.ID, formatAnnouncementTime(announcement.SendAt, app.announcementLocation())))
+			continue
+		}
+
+		app.setAnnouncementStatus(announcement.ID, announcementStatusSent)
+		slog.Info("Sending announcement", "id", announcement.ID)
+		if err := app.sendPost(&announcement.Post, app.config.ChatID, app.config.ThreadID); err != nil {
+			slog.Error("Error sending announcement", "id", announcement.ID, "error", err)
+			app.setAnnouncementStatus(announcement.ID, announcementStatusFailed)
+			app.alertAdmins(fmt.Sprintf("Не получилось отправить публикацию №%d: %s", announcement.ID, err))
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func announcementsTestConfig(config *Config) {
+	config.ThreadID = 7
+	config.Announcements = Announcements{Timezone: "Europe/Moscow"}
+}
+
+func TestScheduleAnnouncement(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "member")
+	sendAt := time.Now().Add(48 * time.Hour).In(app.announcementLocation())
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/schedule " + sendAt.Format(announcementTimeLayout)))
+	if text := lastSentText(t, api); !strings.Contains(text, "Пришлите текст или фото") {
+		t.Fatalf("Expected a prompt for the post, got %s", text)
+	}
+
+	post := createTestPrivateMessage("")
+	post.Message.Caption = "Новинки недели\nКаталог | https://example.com/catalog"
+	post.Message.Photo = []PhotoSize{{FileID: "small"}, {FileID: "large"}}
+	app.handleTelegramUpdate(post)
+
+	preview := api.callsTo("sendPhoto")
+	if len(preview) != 1 || preview[0].Params["chat_id"] != float64(testCustomerID) || preview[0].Params["photo"] != "large" || preview[0].Params["caption"] != "Новинки недели" {
+		t.Errorf("Expected a photo preview in the private chat, got %v", preview)
+	}
+	if text := lastSentText(t, api); !strings.Contains(text, "Публикация №1 запланирована на "+formatAnnouncementTime(sendAt, app.announcementLocation())) {
+		t.Errorf("Expected a confirmation, got %s", text)
+	}
+
+	pending := app.pendingAnnouncements()
+	if len(pending) != 1 || len(pending[0].Buttons) != 1 || pending[0].SendAt.Unix() != sendAt.Truncate(time.Minute).Unix() {
+		t.Fatalf("Expected 1 pending announcement, got %+v", pending)
+	}
+
+	app.runAnnouncements(sendAt.Add(-time.Minute))
+	if calls := api.callsTo("sendPhoto"); len(calls) != 1 {
+		t.Errorf("Expected nothing to be posted early, got %v", calls)
+	}
+
+	app.runAnnouncements(sendAt.Add(time.Minute))
+	app.runAnnouncements(sendAt.Add(2 * time.Minute))
+	calls := api.callsTo("sendPhoto")
+	if len(calls) != 2 {
+		t.Fatalf("Expected the announcement to be posted once, got %d photos", len(calls))
+	}
+	if calls[1].Params["chat_id"] != float64(123456789) || calls[1].Params["message_thread_id"] != float64(7) {
+		t.Errorf("Expected the post in the group topic, got %v", calls[1].Params)
+	}
+	if len(app.pendingAnnouncements()) != 0 {
+		t.Error("Expected no pending announcements")
+	}
+}
+
+func TestScheduleCommandRequiresAdmin(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "left")
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/schedule 2030-11-01 10:00"))
+
+	if calls := api.callsTo("sendMessage"); len(calls) != 0 {
+		t.Errorf("Expected the command to be ignored, got %v", calls)
+	}
+	if app.isAnnouncementMessage(createTestPrivateMessage("Новинки").Message) {
+		t.Error("Expected no draft for a stranger")
+	}
+}
+
+func TestScheduledListAndUnschedule(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "administrator")
+	location := app.announcementLocation()
+	app.store.update(func(data *storeData) {
+		data.Announcements = []*Announcement{
+			{ID: 1, Post: Post{Text: "Приём заказов до пятницы"}, SendAt: time.Date(2030, 11, 2, 10, 0, 0, 0, location), Status: announcementStatusPending},
+			{ID: 2, Post: Post{Text: "Новинки недели\nЛаванда"}, SendAt: time.Date(2030, 11, 1, 10, 0, 0, 0, location), Status: announcementStatusPending},
+			{ID: 3, Post: Post{Text: "Старая"}, SendAt: time.Date(2020, 11, 1, 10, 0, 0, 0, location), Status: announcementStatusSent},
+		}
+	})
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/scheduled"))
+	expected := "Запланированные публикации:\n№2 — 01.11.2030 10:00 — Новинки недели\n№1 — 02.11.2030 10:00 — Приём заказов до пятницы"
+	if text := lastSentText(t, api); !strings.HasPrefix(text, expected) {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/preview 2"))
+	if text := lastSentText(t, api); text != "Новинки недели\nЛаванда" {
+		t.Errorf("Expected the preview, got %q", text)
+	}
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/unschedule 2"))
+	app.handleTelegramUpdate(createTestPrivateMessage("/unschedule 3"))
+	if text := lastSentText(t, api); !strings.Contains(text, "не найдена") {
+		t.Errorf("Expected a sent announcement not to be cancelled, got %q", text)
+	}
+	if pending := app.pendingAnnouncements(); len(pending) != 1 || pending[0].ID != 1 {
+		t.Errorf("Expected only announcement 1 to stay, got %+v", pending)
+	}
+}
+
+func TestRunAnnouncementsSkipsMissed(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "member")
+	sendAt := time.Date(2030, 11, 1, 10, 0, 0, 0, time.UTC)
+	app.store.update(func(data *storeData) {
+		data.Announcements = []*Announcement{{ID: 1, Post: Post{Text: "Новинки"}, SendAt: sendAt, Status: announcementStatusPending}}
+	})
+
+	app.runAnnouncements(sendAt.Add(3 * time.Hour))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["chat_id"] != float64(555) {
+		t.Errorf("Expected only an alert to admins, got %v", calls)
+	}
+	app.store.view(func(data *storeData) {
+		if data.Announcements[0].Status != announcementStatusMissed {
+			t.Errorf("Expected missed status, got %s", data.Announcements[0].Status)
+		}
+	})
+}
+
+func TestBroadcastReplacesScheduleDraft(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "member")
+	sendAt := time.Now().Add(48 * time.Hour).In(app.announcementLocation())
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/schedule " + sendAt.Format(announcementTimeLayout)))
+	app.handleTelegramUpdate(createTestPrivateMessage("/broadcast"))
+	app.handleTelegramUpdate(createTestPrivateMessage("Новости для подписчиков"))
+
+	app.store.view(func(data *storeData) {
+		if len(data.Announcements) != 0 || len(data.AnnouncementDrafts) != 0 {
+			t.Error("Expected the /schedule draft to be dropped")
+		}
+		if len(data.Broadcasts) != 1 || data.Broadcasts[0].Text != "Новости для подписчиков" {
+			t.Errorf("Expected the post to become a broadcast, got %v", data.Broadcasts)
+		}
+	})
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/broadcast"))
+	app.handleTelegramUpdate(createTestPrivateMessage("/schedule " + sendAt.Format(announcementTimeLayout)))
+	app.store.view(func(data *storeData) {
+		if len(data.BroadcastDrafts) != 0 || len(data.AnnouncementDrafts) != 1 {
+			t.Error("Expected /schedule to drop the /broadcast draft")
+		}
+	})
+}
+
+func TestPostDraftsExpire(t *testing.T) {
+	app, api := newTestApp(t, announcementsTestConfig)
+	setChatMemberStatus(api, "member")
+	startedAt := time.Now().Add(-postDraftTimeout - time.Minute)
+	app.store.update(func(data *storeData) {
+		data.AnnouncementDrafts[111222333] = &AnnouncementDraft{SendAt: time.Now().Add(time.Hour), StartedAt: startedAt}
+		data.BroadcastDrafts[444] = startedAt
+		data.BroadcastDrafts[555] = time.Now()
+	})
+
+	if app.isAnnouncementMessage(createTestPrivateMessage("Привет").Message) {
+		t.Error("Expected an expired draft to let the message through")
+	}
+
+	app.expirePostDrafts(time.Now())
+	app.store.view(func(data *storeData) {
+		if len(data.AnnouncementDrafts) != 0 || len(data.BroadcastDrafts) != 1 {
+			t.Errorf("Expected only the fresh draft to stay, got %v and %v", data.AnnouncementDrafts, data.BroadcastDrafts)
+		}
+	})
+}
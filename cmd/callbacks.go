@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Telegram rejects buttons with longer callback_data
+	maxCallbackDataLength = 64
+	// 48 bits of HMAC, 8 characters of base64, are plenty against guessing through button presses
+	callbackSignatureSize = 6
+
+	noopCallbackName    = "noop"
+	expiredCallbackText = "Кнопка устарела, откройте меню заново"
+)
+
+// CallbackHandler handles a button press, the returned text is shown to the user as a notification
+type CallbackHandler func(query *CallbackQuery, args callbackArgs) string
+
+// callbackArgs are the arguments a button was created with, numbers are decoded by the typed getters
+type callbackArgs []string
+
+func (a callbackArgs) string(i int) string {
+	if i < 0 || i >= len(a) {
+		return ""
+	}
+	return a[i]
+}
+
+func (a callbackArgs) int64(i int) (int64, bool) {
+	value, err := strconv.ParseInt(a.string(i), 36, 64)
+	return value, err == nil
+}
+
+func (a callbackArgs) int(i int) (int, bool) {
+	value, err := strconv.ParseInt(a.string(i), 36, 0)
+	return int(value), err == nil
+}
+
+// CallbackRouter builds and dispatches callback_data of inline buttons. The data is
+// "name:arg:...:signature", signed so that users can't craft a press of a button
+// they were never shown, like approving their own join request.
+type CallbackRouter struct {
+	key      []byte
+	handlers map[string]CallbackHandler
+}
+
+// newCallbackRouter derives the signing key from the bot token, so changing the token
+// turns all earlier buttons stale
+func newCallbackRouter(token string) *CallbackRouter {
+	key := sha256.Sum256([]byte("callback_data:" + token))
+	return &CallbackRouter{
+		key:      key[:],
+		handlers: make(map[string]CallbackHandler),
+	}
+}
+
+func (r *CallbackRouter) handle(name string, handler CallbackHandler) {
+	r.handlers[name] = handler
+}
+
+// encodeCallbackArg keeps numbers short, ids of users and chats take 5-7 characters in base 36
+func encodeCallbackArg(arg any) string {
+	switch value := arg.(type) {
+	case int:
+		return strconv.FormatInt(int64(value), 36)
+	case int64:
+		return strconv.FormatInt(value, 36)
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (r *CallbackRouter) signature(payload string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureSize])
+}
+
+// sign appends the signature to a "name:arg:..." payload
+func (r *CallbackRouter) sign(payload string) string {
+	data := payload + ":" + r.signature(payload)
+	if len(data) > maxCallbackDataLength {
+		slog.Error("Callback data is too long", "data", data, "length", len(data))
+	}
+	return data
+}
+
+// data returns the callback_data of a button that runs the handler of name with args
+func (r *CallbackRouter) data(name string, args ...any) string {
+	parts := []string{name}
+	for _, arg := range args {
+		encoded := encodeCallbackArg(arg)
+		if strings.Contains(encoded, ":") {
+			slog.Error("Callback argument contains a separator", "name", name, "arg", encoded)
+		}
+		parts = append(parts, encoded)
+	}
+	return r.sign(strings.Join(parts, ":"))
+}
+
+// parse checks the signature of data and splits it into the handler name and arguments
+func (r *CallbackRouter) parse(data string) (string, callbackArgs, bool) {
+	payload, signature, found := cutLast(data, ":")
+	if !found || !hmac.Equal([]byte(signature), []byte(r.signature(payload))) {
+		return "", nil, false
+	}
+	parts := strings.Split(payload, ":")
+	return parts[0], parts[1:], true
+}
+
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	index := strings.LastIndex(s, sep)
+	if index < 0 {
+		return s, "", false
+	}
+	return s[:index], s[index+len(sep):], true
+}
+
+// dispatch runs the handler of the pressed button and returns the text to answer the query with.
+// Forged data and buttons sent before an update of the bot get a notice instead.
+func (r *CallbackRouter) dispatch(query *CallbackQuery) string {
+	name, args, ok := r.parse(query.Data)
+	if !ok {
+		slog.Warn("Rejected callback data", "data", query.Data, "user_id", query.From.ID)
+		return expiredCallbackText
+	}
+	handler, ok := r.handlers[name]
+	if !ok {
+		slog.Warn("Unknown callback", "name", name, "user_id", query.From.ID)
+		return expiredCallbackText
+	}
+	return handler(query, args)
+}
+
+func (app *App) registerCallbacks() {
+	// The page counter between the arrows of paginated screens
+	app.callbacks.handle(noopCallbackName, func(query *CallbackQuery, args callbackArgs) string { return "" })
+	app.callbacks.handle(joinCallbackPrefix, app.handleJoinReviewCallback)
+	app.callbacks.handle(catalogCallbackPrefix, app.handleCatalogCallback)
+	app.callbacks.handle(orderCallbackPrefix, app.handleOrderCallback)
+	app.callbacks.handle(orderStatusCallbackPrefix, app.handleOrderStatusCallback)
+	app.callbacks.handle(broadcastCallbackPrefix, app.handleBroadcastCallback)
+}
+
+// callbackButton is an inline keyboard button for the callback name with args
+func (app *App) callbackButton(text string, name string, args ...any) map[string]string {
+	return map[string]string{"text": text, "callback_data": app.callbacks.data(name, args...)}
+}
+
+// handleCallbackQuery answers every query, Telegram shows a spinner on the button until then
+func (app *App) handleCallbackQuery(query *CallbackQuery) {
+	app.answerCallback(query, app.callbacks.dispatch(query))
+}
+
+func (app *App) answerCallback(query *CallbackQuery, text string) {
+	if err := app.telegram.answerCallbackQuery(query.ID, text); err != nil {
+		slog.Error("Error answering callback query", "error", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCallbackDataRoundTrip(t *testing.T) {
+	router := newCallbackRouter("test_token")
+
+	data := router.data("status", int64(111222333), "accepted", 7)
+	name, args, ok := router.parse(data)
+	if !ok || name != "status" {
+		t.Fatalf("Expected status callback, got %q %v", name, ok)
+	}
+	if id, ok := args.int64(0); !ok || id != 111222333 {
+		t.Errorf("Expected id 111222333, got %d", id)
+	}
+	if status := args.string(1); status != "accepted" {
+		t.Errorf("Expected accepted, got %s", status)
+	}
+	if page, ok := args.int(2); !ok || page != 7 {
+		t.Errorf("Expected page 7, got %d", page)
+	}
+	if args.string(3) != "" {
+		t.Errorf("Expected an empty missing argument, got %q", args.string(3))
+	}
+}
+
+func TestCallbackDataFitsLimit(t *testing.T) {
+	router := newCallbackRouter("test_token")
+
+	tests := []string{
+		router.data(joinCallbackPrefix, joinActionDecline, int64(math.MinInt64)),
+		router.data(broadcastCallbackPrefix, "cancel", int64(math.MaxInt64)),
+		router.data(catalogCallbackPrefix, "p", strings.Repeat("x", 32)),
+	}
+
+	for _, data := range tests {
+		if len(data) > maxCallbackDataLength {
+			t.Errorf("Expected at most %d bytes, got %d in %s", maxCallbackDataLength, len(data), data)
+		}
+	}
+}
+
+func TestCallbackDataRejectsForgery(t *testing.T) {
+	router := newCallbackRouter("test_token")
+	data := router.data(joinCallbackPrefix, joinActionDecline, int64(42))
+	_, signature, _ := cutLast(data, ":")
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "changed argument", data: strings.Replace(data, "decline", "approve", 1)},
+		{name: "unsigned", data: "join:approve:16"},
+		{name: "signature of other data", data: "join:approve:16:" + signature},
+		{name: "other token", data: newCallbackRouter("other_token").data(joinCallbackPrefix, joinActionApprove, int64(42))},
+		{name: "empty", data: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, ok := router.parse(tt.data); ok {
+				t.Errorf("Expected %q to be rejected", tt.data)
+			}
+		})
+	}
+}
+
+func TestCallbackQueryIsAnswered(t *testing.T) {
+	app, api := newTestApp(t, nil)
+	var pressed callbackArgs
+	app.callbacks.handle("test", func(query *CallbackQuery, args callbackArgs) string {
+		pressed = args
+		return "Готово"
+	})
+
+	tests := []struct {
+		name         string
+		data         string
+		expectedText string
+		expectedArgs string
+	}{
+		{name: "handler", data: app.callbacks.data("test", "a", 1), expectedText: "Готово", expectedArgs: "a,1"},
+		{name: "forged", data: "test:b:2", expectedText: expiredCallbackText},
+		{name: "unknown handler", data: app.callbacks.data("removed"), expectedText: expiredCallbackText},
+		{name: "noop", data: app.callbacks.data(noopCallbackName), expectedText: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pressed = nil
+			app.handleTelegramUpdate(&Update{CallbackQuery: &CallbackQuery{ID: "query", Data: tt.data}})
+
+			answers := api.callsTo("answerCallbackQuery")
+			if len(answers) == 0 {
+				t.Fatal("Expected the callback query to be answered")
+			}
+			if text, _ := answers[len(answers)-1].Params["text"].(string); text != tt.expectedText {
+				t.Errorf("Expected answer %q, got %q", tt.expectedText, text)
+			}
+			if args := strings.Join(pressed, ","); args != tt.expectedArgs {
+				t.Errorf("Expected args %q, got %q", tt.expectedArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxUnknownCampaigns caps the payloads outside [[campaigns]] counted on their own,
+	// anyone can make up a link, so starts from newer ones are counted together
+	maxUnknownCampaigns = 50
+	// otherCampaigns is where the starts over the cap go, it never matches startPayloadPattern
+	otherCampaigns = "*"
+)
+
+// startPayloadPattern is what Telegram allows after ?start= in a deep link
+var startPayloadPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// CampaignUser is the first campaign that brought a user to the bot
+type CampaignUser struct {
+	Campaign  string    `json:"campaign"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// isReservedStartPayload reports whether payload starts one of the bot's own flows rather than names a campaign
+func isReservedStartPayload(payload string) bool {
+	command, _, _ := strings.Cut(payload, "-")
+	return payload == subscribeStartPayload || payload == welcomeStartPayload || command == orderStartPayload
+}
+
+func (app *App) findCampaign(id string) (Campaign, bool) {
+	index := slices.IndexFunc(app.config.Campaigns, func(campaign Campaign) bool { return campaign.ID == id })
+	if index < 0 {
+		return Campaign{}, false
+	}
+	return app.config.Campaigns[index], true
+}
+
+// countedCampaign is the key the starts of campaign are counted under
+func (app *App) countedCampaign(data *storeData, campaign string) string {
+	if _, ok := data.CampaignStarts[campaign]; ok {
+		return campaign
+	}
+	if _, ok := app.findCampaign(campaign); ok {
+		return campaign
+	}
+	unknown := 0
+	for counted := range data.CampaignStarts {
+		if _, ok := app.findCampaign(counted); !ok && counted != otherCampaigns {
+			unknown++
+		}
+	}
+	if unknown >= maxUnknownCampaigns {
+		return otherCampaigns
+	}
+	return campaign
+}
+
+// recordCampaignStart counts a /start from a campaign link, the user is attributed to the first campaign only
+func (app *App) recordCampaignStart(user User, campaign string, now time.Time) {
+	err := app.store.update(func(data *storeData) {
+		campaign = app.countedCampaign(data, campaign)
+		data.CampaignStarts[campaign]++
+		if _, ok := data.CampaignUsers[user.ID]; !ok {
+			data.CampaignUsers[user.ID] = &CampaignUser{Campaign: campaign, StartedAt: now}
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving campaign start", "campaign", campaign, "user_id", user.ID, "error", err)
+	}
+}
+
+// handleCampaignStart greets a user who came from a campaign link and reports whether it did
+func (app *App) handleCampaignStart(message *Message, payload string) bool {
+	if !startPayloadPattern.MatchString(payload) || isReservedStartPayload(payload) {
+		return false
+	}
+	slog.Info("Campaign start", "campaign", payload, "user_id", message.From.ID)
+	app.recordCampaignStart(message.From, payload, time.Now())
+
+	campaign, ok := app.findCampaign(payload)
+	if !ok || campaign.Greeting == "" {
+		return false
+	}
+	links := app.links()
+	params := map[string]any{
+		"text":       renderLinks(campaign.Greeting, &links),
+		"parse_mode": "HTML",
+	}
+	if len(campaign.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(app.trackButtons(campaign.Buttons, message.Chat.ID))
+	}
+	app.reply(message, params)
+	return true
+}
+
+type campaignStats struct {
+	campaign    string
+	starts      int
+	users       int
+	subscribers int
+	customers   int
+}
+
+func countCampaigns(data *storeData) map[string]*campaignStats {
+	stats := make(map[string]*campaignStats)
+	get := func(campaign string) *campaignStats {
+		if stats[campaign] == nil {
+			stats[campaign] = &campaignStats{campaign: campaign}
+		}
+		return stats[campaign]
+	}
+	for campaign, starts := range data.CampaignStarts {
+		get(campaign).starts = starts
+	}
+	for userID, user := range data.CampaignUsers {
+		s := get(user.Campaign)
+		s.users++
+		if _, ok := data.Subscribers[userID]; ok {
+			s.subscribers++
+		}
+		if hasOrders(data, userID) {
+			s.customers++
+		}
+	}
+	return stats
+}
+
+func formatConversion(count int, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d (%d%%)", count, count*100/total)
+}
+
+func createCampaignsReport(stats map[string]*campaignStats, campaigns []Campaign) []string {
+	if len(stats) == 0 {
+		return []string{"Переходов по ссылкам кампаний пока не было. Ссылка выглядит так: https://t.me/<бот>?start=instagram_oct"}
+	}
+
+	// Configured campaigns first in config order, then the ones only seen in links
+	names := make(map[string]string)
+	var order []string
+	for _, campaign := range campaigns {
+		names[campaign.ID] = campaign.Name
+		order = append(order, campaign.ID)
+	}
+	var unknown []string
+	for campaign := range stats {
+		if _, ok := names[campaign]; !ok && campaign != otherCampaigns {
+			unknown = append(unknown, campaign)
+		}
+	}
+	slices.Sort(unknown)
+	order = append(order, unknown...)
+	if _, ok := stats[otherCampaigns]; ok {
+		order = append(order, otherCampaigns)
+	}
+
+	lines := []string{"Кампании (переходы / пользователи / подписались / заказали):", ""}
+	for _, campaign := range order {
+		s := stats[campaign]
+		if s == nil {
+			s = &campaignStats{campaign: campaign}
+		}
+		title := campaign
+		if name := names[campaign]; name != "" {
+			title += " — " + name
+		}
+		if campaign == otherCampaigns {
+			title = fmt.Sprintf("Другие ссылки (после первых %d)", maxUnknownCampaigns)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %d / %d / %s / %s",
+			title, s.starts, s.users, formatConversion(s.subscribers, s.users), formatConversion(s.customers, s.users)))
+	}
+	return lines
+}
+
+func (app *App) handleCampaignsCommand(message *Message, args string) {
+	var stats map[string]*campaignStats
+	app.store.view(func(data *storeData) {
+		stats = countCampaigns(data)
+	})
+	app.replyLines(message, createCampaignsReport(stats, app.config.Campaigns))
+}
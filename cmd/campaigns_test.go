@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func campaignsTestConfig(config *Config) {
+	config.Campaigns = []Campaign{
+		{ID: "fair_oct", Name: "Ярмарка в октябре", Greeting: "Рады видеть вас после ярмарки!"},
+		{ID: "instagram_oct", Name: "Instagram"},
+	}
+}
+
+func TestIsReservedStartPayload(t *testing.T) {
+	tests := []struct {
+		payload  string
+		expected bool
+	}{
+		{payload: "subscribe", expected: true},
+		{payload: "welcome", expected: true},
+		{payload: "order", expected: true},
+		{payload: "order-lavender", expected: true},
+		{payload: "instagram_oct", expected: false},
+		{payload: "orders", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			if result := isReservedStartPayload(tt.payload); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCampaignStart(t *testing.T) {
+	app, api := newTestApp(t, campaignsTestConfig)
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{text: "/start fair_oct", expected: "Рады видеть вас после ярмарки!"},
+		{text: "/start instagram_oct", expected: "Здравствуйте!"},
+		{text: "/start кампания", expected: "Здравствуйте!"},
+	}
+
+	for _, tt := range tests {
+		app.handleTelegramUpdate(createTestPrivateMessage(tt.text))
+		if text := lastSentText(t, api); !strings.HasPrefix(text, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.text, tt.expected, text)
+		}
+	}
+
+	app.store.view(func(data *storeData) {
+		if user := data.CampaignUsers[testCustomerID]; user == nil || user.Campaign != "fair_oct" {
+			t.Errorf("Expected the user to stay attributed to the first campaign, got %+v", user)
+		}
+		if data.CampaignStarts["fair_oct"] != 1 || data.CampaignStarts["instagram_oct"] != 1 || len(data.CampaignStarts) != 2 {
+			t.Errorf("Expected a start of each valid campaign, got %v", data.CampaignStarts)
+		}
+	})
+}
+
+func TestCampaignsReport(t *testing.T) {
+	app, api := newTestApp(t, campaignsTestConfig)
+	now := time.Now()
+	for _, userID := range []int64{1001, 1002, 1003, 1004} {
+		app.recordCampaignStart(User{ID: userID}, "fair_oct", now)
+	}
+	app.recordCampaignStart(User{ID: 1001}, "fair_oct", now)
+	app.recordCampaignStart(User{ID: 1005}, "vk_story", now)
+	app.subscribe(User{ID: 1001}, now)
+	app.subscribe(User{ID: 1002}, now)
+	addTestOrder(app, 1, 1003, orderStatusNew)
+
+	app.handleTelegramUpdate(createTestCommand("/campaigns"))
+	if calls := api.callsTo("sendMessage"); len(calls) != 0 {
+		t.Errorf("Expected /campaigns to be ignored outside the admin chat, got %v", calls)
+	}
+
+	app.handleTelegramUpdate(&Update{Message: &Message{Text: "/campaigns", Chat: Chat{ID: 555}}})
+	expected := "fair_oct — Ярмарка в октябре: 5 / 4 / 2 (50%) / 1 (25%)\n" +
+		"instagram_oct — Instagram: 0 / 0 / 0 / 0\n" +
+		"vk_story: 1 / 1 / 0 (0%) / 0 (0%)"
+	if text := lastSentText(t, api); !strings.HasSuffix(text, expected) {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+}
+
+func TestUnknownCampaignsAreCapped(t *testing.T) {
+	app, api := newTestApp(t, campaignsTestConfig)
+	now := time.Now()
+	for i := range maxUnknownCampaigns + 5 {
+		app.recordCampaignStart(User{ID: int64(2000 + i)}, fmt.Sprintf("made_up_%03d", i), now)
+	}
+	app.recordCampaignStart(User{ID: 1001}, "fair_oct", now)
+	app.recordCampaignStart(User{ID: 1002}, "made_up_000", now)
+
+	app.store.view(func(data *storeData) {
+		if len(data.CampaignStarts) != maxUnknownCampaigns+2 {
+			t.Errorf("Expected %d counted payloads, got %d", maxUnknownCampaigns+2, len(data.CampaignStarts))
+		}
+		if data.CampaignStarts[otherCampaigns] != 5 || data.CampaignStarts["fair_oct"] != 1 || data.CampaignStarts["made_up_000"] != 2 {
+			t.Errorf("Expected new payloads over the cap to be counted together, got %v", data.CampaignStarts)
+		}
+	})
+
+	app.handleTelegramUpdate(&Update{Message: &Message{Text: "/campaigns", Chat: Chat{ID: 555}}})
+	if text := lastSentText(t, api); !strings.HasSuffix(text, fmt.Sprintf("Другие ссылки (после первых %d): 5 / 5 / 0 (0%%) / 0 (0%%)", maxUnknownCampaigns)) {
+		t.Errorf("Expected the other links last in the report, got %q", text)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	catalogCallbackPrefix = "catalog"
+	catalogPageSize       = 6
+)
+
+// productIDPattern keeps product ids usable in callback data, which is limited to 64 bytes,
+// and in /start deep links
+var productIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// catalogView is one screen of the catalog, sent by /catalog and edited in place by its buttons
+type catalogView struct {
+	text     string
+	keyboard [][]map[string]string
+	photo    string
+}
+
+func (v *catalogView) params() map[string]any {
+	params := map[string]any{
+		"text":         v.text,
+		"parse_mode":   "HTML",
+		"reply_markup": map[string]any{"inline_keyboard": v.keyboard},
+	}
+	// The product photo is shown as a large link preview above the text,
+	// so moving between text-only screens and product cards can stay a plain editMessageText
+	if v.photo != "" {
+		params["link_preview_options"] = map[string]any{
+			"url":                v.photo,
+			"prefer_large_media": true,
+			"show_above_text":    true,
+		}
+	} else {
+		params["link_preview_options"] = map[string]any{"is_disabled": true}
+	}
+	return params
+}
+
+func productCategories(products []Product) []string {
+	var categories []string
+	for _, product := range products {
+		if !slices.Contains(categories, product.Category) {
+			categories = append(categories, product.Category)
+		}
+	}
+	return categories
+}
+
+func categoryProducts(products []Product, category string) []Product {
+	var result []Product
+	for _, product := range products {
+		if product.Category == category {
+			result = append(result, product)
+		}
+	}
+	return result
+}
+
+// pageBounds clamps page into range and returns the slice bounds of its items
+func pageBounds(total int, page int, size int) (start int, end int, clamped int, pages int) {
+	pages = max((total+size-1)/size, 1)
+	clamped = min(max(page, 0), pages-1)
+	start = clamped * size
+	end = min(start+size, total)
+	return start, end, clamped, pages
+}
+
+func formatPrice(price int) string {
+	return fmt.Sprintf("%d ₽", price)
+}
+
+// navigationRow returns the previous/next buttons for a paginated screen, the counter in between does nothing
+func (app *App) navigationRow(page int, pages int, button func(text string, page int) map[string]string) []map[string]string {
+	if pages <= 1 {
+		return nil
+	}
+	var row []map[string]string
+	if page > 0 {
+		row = append(row, button("‹", page-1))
+	}
+	row = append(row, app.callbackButton(fmt.Sprintf("%d / %d", page+1, pages), noopCallbackName))
+	if page < pages-1 {
+		row = append(row, button("›", page+1))
+	}
+	return row
+}
+
+func (app *App) catalogCategoriesView(page int) *catalogView {
+	products := app.config.Products
+	categories := productCategories(products)
+	start, end, page, pages := pageBounds(len(categories), page, catalogPageSize)
+
+	view := &catalogView{text: "Каталог мастерской «Мыльная Мама». Выберите категорию:"}
+	for i := start; i < end; i++ {
+		count := len(categoryProducts(products, categories[i]))
+		view.keyboard = append(view.keyboard, []map[string]string{
+			app.callbackButton(fmt.Sprintf("%s (%d)", categories[i], count), catalogCallbackPrefix, "c", i, 0),
+		})
+	}
+	row := app.navigationRow(page, pages, func(text string, page int) map[string]string {
+		return app.callbackButton(text, catalogCallbackPrefix, "h", page)
+	})
+	if row != nil {
+		view.keyboard = append(view.keyboard, row)
+	}
+	return view
+}
+
+func (app *App) catalogCategoryView(categoryIndex int, page int) (*catalogView, bool) {
+	categories := productCategories(app.config.Products)
+	if categoryIndex < 0 || categoryIndex >= len(categories) {
+		return nil, false
+	}
+	category := categories[categoryIndex]
+	products := categoryProducts(app.config.Products, category)
+	start, end, page, pages := pageBounds(len(products), page, catalogPageSize)
+
+	view := &catalogView{text: fmt.Sprintf("<b>%s</b>", html.EscapeString(category))}
+	for _, product := range products[start:end] {
+		text := product.Name + " — " + formatPrice(product.Price)
+		if !product.InStock {
+			text += " (нет в наличии)"
+		}
+		view.keyboard = append(view.keyboard, []map[string]string{
+			app.callbackButton(text, catalogCallbackPrefix, "p", product.ID),
+		})
+	}
+	row := app.navigationRow(page, pages, func(text string, page int) map[string]string {
+		return app.callbackButton(text, catalogCallbackPrefix, "c", categoryIndex, page)
+	})
+	if row != nil {
+		view.keyboard = append(view.keyboard, row)
+	}
+	view.keyboard = append(view.keyboard, []map[string]string{
+		app.callbackButton("« Категории", catalogCallbackPrefix, "h", categoryIndex/catalogPageSize),
+	})
+	return view, true
+}
+
+func createProductCard(product *Product) string {
+	lines := []string{
+		fmt.Sprintf("<b>%s</b>", html.EscapeString(product.Name)),
+		formatPrice(product.Price),
+	}
+	if !product.InStock {
+		lines = append(lines, "Нет в наличии")
+	}
+	if product.Description != "" {
+		lines = append(lines, "", html.EscapeString(product.Description))
+	}
+	if len(product.Ingredients) > 0 {
+		lines = append(lines, "", "Состав: "+html.EscapeString(strings.Join(product.Ingredients, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) catalogProductView(productID string) (*catalogView, bool) {
+	products := app.config.Products
+	index := slices.IndexFunc(products, func(product Product) bool { return product.ID == productID })
+	if index < 0 {
+		return nil, false
+	}
+	product := &products[index]
+	categoryIndex := slices.Index(productCategories(products), product.Category)
+	position := slices.IndexFunc(categoryProducts(products, product.Category), func(p Product) bool { return p.ID == productID })
+
+	view := &catalogView{text: createProductCard(product), photo: product.Photo}
+	view.keyboard = [][]map[string]string{
+		{app.callbackButton("« "+product.Category, catalogCallbackPrefix, "c", categoryIndex, position/catalogPageSize)},
+	}
+	return view, true
+}
+
+// catalogViewForCallback renders the screen for the arguments "h <page>", "c <category> <page>" and "p <product id>"
+func (app *App) catalogViewForCallback(args callbackArgs) (*catalogView, bool) {
+	switch {
+	case args.string(0) == "h" && len(args) == 2:
+		page, ok := args.int(1)
+		if !ok {
+			return nil, false
+		}
+		return app.catalogCategoriesView(page), true
+	case args.string(0) == "c" && len(args) == 3:
+		categoryIndex, ok := args.int(1)
+		if !ok {
+			return nil, false
+		}
+		page, ok := args.int(2)
+		if !ok {
+			return nil, false
+		}
+		return app.catalogCategoryView(categoryIndex, page)
+	case args.string(0) == "p" && len(args) == 2:
+		return app.catalogProductView(args.string(1))
+	}
+	return nil, false
+}
+
+func (app *App) handleCatalogCommand(message *Message, args string) {
+	if len(app.config.Products) == 0 {
+		app.replyText(message, "Каталог пока пуст.")
+		return
+	}
+	app.reply(message, app.catalogCategoriesView(0).params())
+}
+
+func (app *App) handleCatalogCallback(query *CallbackQuery, args callbackArgs) string {
+	if query.Message == nil {
+		return ""
+	}
+	view, ok := app.catalogViewForCallback(args)
+	if !ok {
+		// Old keyboards may point at products removed from the config
+		return "Товар не найден, откройте /catalog заново"
+	}
+
+	params := view.params()
+	params["chat_id"] = query.Message.Chat.ID
+	params["message_id"] = query.Message.MessageID
+	if err := app.telegram.editMessageText(params); err != nil {
+		slog.Error("Error updating catalog message", "data", query.Data, "error", err)
+	}
+	return ""
+}
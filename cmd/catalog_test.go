@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func catalogTestConfig(config *Config) {
+	config.Products = []Product{
+		{ID: "lavender", Name: "Лавандовое мыло", Category: "Мыло", Price: 450, Description: "С маслом <лаванды>", Photo: "https://example.com/lavender.jpg", InStock: true},
+		{ID: "rose", Name: "Розовый гидролат", Category: "Гидролаты", Price: 600, InStock: true},
+		{ID: "mint", Name: "Мятное мыло", Category: "Мыло", Price: 400},
+	}
+	for i := range 7 {
+		config.Products = append(config.Products, Product{
+			ID: fmt.Sprintf("ubtan%d", i), Name: fmt.Sprintf("Убтан %d", i), Category: "Убтаны", Price: 300, InStock: true,
+		})
+	}
+}
+
+// keyboardData returns the payloads of the buttons without signatures, a button with a bad signature is kept as is
+func keyboardData(app *App, keyboard [][]map[string]string) []string {
+	var data []string
+	for _, row := range keyboard {
+		for _, button := range row {
+			payload := button["callback_data"]
+			if _, _, ok := app.callbacks.parse(payload); ok {
+				payload, _, _ = cutLast(payload, ":")
+			}
+			data = append(data, payload)
+		}
+	}
+	return data
+}
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		name                                                    string
+		total, page, size                                       int
+		expectedStart, expectedEnd, expectedPage, expectedPages int
+	}{
+		{name: "first page", total: 7, page: 0, size: 6, expectedStart: 0, expectedEnd: 6, expectedPage: 0, expectedPages: 2},
+		{name: "last page", total: 7, page: 1, size: 6, expectedStart: 6, expectedEnd: 7, expectedPage: 1, expectedPages: 2},
+		{name: "page past the end", total: 7, page: 5, size: 6, expectedStart: 6, expectedEnd: 7, expectedPage: 1, expectedPages: 2},
+		{name: "negative page", total: 3, page: -1, size: 6, expectedStart: 0, expectedEnd: 3, expectedPage: 0, expectedPages: 1},
+		{name: "empty", total: 0, page: 0, size: 6, expectedStart: 0, expectedEnd: 0, expectedPage: 0, expectedPages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, page, pages := pageBounds(tt.total, tt.page, tt.size)
+			if start != tt.expectedStart || end != tt.expectedEnd || page != tt.expectedPage || pages != tt.expectedPages {
+				t.Errorf("Expected %d %d %d %d, got %d %d %d %d",
+					tt.expectedStart, tt.expectedEnd, tt.expectedPage, tt.expectedPages, start, end, page, pages)
+			}
+		})
+	}
+}
+
+func TestProductCategories(t *testing.T) {
+	app, _ := newTestApp(t, catalogTestConfig)
+	categories := productCategories(app.config.Products)
+	expected := "Мыло,Гидролаты,Убтаны"
+	if strings.Join(categories, ",") != expected {
+		t.Errorf("Expected %s, got %v", expected, categories)
+	}
+}
+
+func TestCatalogViewForCallback(t *testing.T) {
+	app, _ := newTestApp(t, catalogTestConfig)
+
+	tests := []struct {
+		name         string
+		data         string
+		expectedOk   bool
+		expectedText string
+		expectedData []string
+	}{
+		{
+			name:         "categories",
+			data:         "catalog:h:0",
+			expectedOk:   true,
+			expectedText: "Выберите категорию",
+			expectedData: []string{"catalog:c:0:0", "catalog:c:1:0", "catalog:c:2:0"},
+		},
+		{
+			name:         "first page of a category",
+			data:         "catalog:c:2:0",
+			expectedOk:   true,
+			expectedText: "<b>Убтаны</b>",
+			expectedData: []string{
+				"catalog:p:ubtan0", "catalog:p:ubtan1", "catalog:p:ubtan2", "catalog:p:ubtan3", "catalog:p:ubtan4", "catalog:p:ubtan5",
+				"noop", "catalog:c:2:1", "catalog:h:0",
+			},
+		},
+		{
+			name:         "last page of a category",
+			data:         "catalog:c:2:1",
+			expectedOk:   true,
+			expectedText: "<b>Убтаны</b>",
+			expectedData: []string{"catalog:p:ubtan6", "catalog:c:2:0", "noop", "catalog:h:0"},
+		},
+		{
+			name:         "product on the second page",
+			data:         "catalog:p:ubtan6",
+			expectedOk:   true,
+			expectedText: "<b>Убтан 6</b>\n300 ₽",
+			expectedData: []string{"catalog:c:2:1"},
+		},
+		{
+			name:         "out of stock product",
+			data:         "catalog:p:mint",
+			expectedOk:   true,
+			expectedText: "Нет в наличии",
+			expectedData: []string{"catalog:c:0:0"},
+		},
+		{name: "unknown product", data: "catalog:p:removed", expectedOk: false},
+		{name: "unknown category", data: "catalog:c:9:0", expectedOk: false},
+		{name: "malformed page", data: "catalog:c:0:?", expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, ok := app.catalogViewForCallback(strings.Split(tt.data, ":")[1:])
+			if ok != tt.expectedOk {
+				t.Fatalf("Expected ok %v, got %v", tt.expectedOk, ok)
+			}
+			if !ok {
+				return
+			}
+			if !strings.Contains(view.text, tt.expectedText) {
+				t.Errorf("Expected text to contain %q, got %q", tt.expectedText, view.text)
+			}
+			if data := keyboardData(app, view.keyboard); strings.Join(data, ",") != strings.Join(tt.expectedData, ",") {
+				t.Errorf("Expected buttons %v, got %v", tt.expectedData, data)
+			}
+		})
+	}
+}
+
+func TestCreateProductCardEscapesHtml(t *testing.T) {
+	card := createProductCard(&Product{Name: "Мыло & скраб", Price: 450, Description: "С маслом <лаванды>", InStock: true})
+	expected := "<b>Мыло &amp; скраб</b>\n450 ₽\n\nС маслом &lt;лаванды&gt;"
+	if card != expected {
+		t.Errorf("Expected %q, got %q", expected, card)
+	}
+}
+
+func TestCatalogCommand(t *testing.T) {
+	app, api := newTestApp(t, catalogTestConfig)
+
+	app.handleTelegramUpdate(createTestCommand("/catalog"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(calls))
+	}
+	if !strings.Contains(calls[0].Params["text"].(string), "Выберите категорию") {
+		t.Errorf("Expected categories, got %s", calls[0].Params["text"])
+	}
+	if calls[0].Params["reply_markup"] == nil {
+		t.Error("Expected category buttons")
+	}
+}
+
+func TestCatalogCommandWithoutProducts(t *testing.T) {
+	app, api := newTestApp(t, nil)
+
+	app.handleTelegramUpdate(createTestCommand("/catalog"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["text"] != "Каталог пока пуст." {
+		t.Errorf("Expected empty catalog reply, got %v", calls)
+	}
+}
+
+func TestCatalogCallbackEditsMessage(t *testing.T) {
+	app, api := newTestApp(t, catalogTestConfig)
+
+	app.handleTelegramUpdate(&Update{CallbackQuery: &CallbackQuery{
+		ID:      "query",
+		Data:    app.callbacks.data(catalogCallbackPrefix, "p", "lavender"),
+		Message: &Message{MessageID: 42, Chat: Chat{ID: 123456789}},
+	}})
+
+	if len(api.callsTo("answerCallbackQuery")) != 1 {
+		t.Error("Expected the callback query to be answered")
+	}
+	edits := api.callsTo("editMessageText")
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 edit, got %d", len(edits))
+	}
+	params := edits[0].Params
+	if params["message_id"] != float64(42) || params["chat_id"] != float64(123456789) {
+		t.Errorf("Expected message 42 in chat 123456789 to be edited, got %v", params)
+	}
+	if !strings.Contains(params["text"].(string), "Лавандовое мыло") {
+		t.Errorf("Expected product card, got %s", params["text"])
+	}
+	preview := params["link_preview_options"].(map[string]any)
+	if preview["url"] != "https://example.com/lavender.jpg" {
+		t.Errorf("Expected product photo as preview, got %v", preview)
+	}
+}
+
+func TestCatalogCallbackForRemovedProduct(t *testing.T) {
+	app, api := newTestApp(t, catalogTestConfig)
+
+	app.handleTelegramUpdate(&Update{CallbackQuery: &CallbackQuery{
+		ID:      "query",
+		Data:    app.callbacks.data(catalogCallbackPrefix, "p", "removed"),
+		Message: &Message{MessageID: 42, Chat: Chat{ID: 123456789}},
+	}})
+
+	if len(api.callsTo("editMessageText")) != 0 {
+		t.Error("Expected no edit for a removed product")
+	}
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params["text"] == nil {
+		t.Errorf("Expected an answer with a notice, got %v", answers)
+	}
+}
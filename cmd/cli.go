@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// allowedUpdates lists the update types the webhook handles
+var allowedUpdates = []string{"message", "callback_query", "chat_join_request", "inline_query", "chat_member"}
+
+// errWebhookSecretNotSet stops serve and set-webhook, without the secret anyone could post updates to /bot
+var errWebhookSecretNotSet = errors.New("WEBHOOK_SECRET is not set")
+
+type cliCommand struct {
+	name        string
+	description string
+	run         func(args []string, stdout io.Writer) error
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{"serve", "start the webhook server (default)", runServe},
+		{"set-webhook", "register the webhook URL with Telegram", runSetWebhook},
+		{"delete-webhook", "remove the webhook from Telegram", runDeleteWebhook},
+		{"webhook-info", "show the webhook registered with Telegram", runWebhookInfo},
+		{"validate-config", "check config.toml and environment variables", runValidateConfig},
+		{"send-test", "send the welcome message to a test chat", runSendTest},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: telegram-bot <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range cliCommands() {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.description)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args, stdout)
+	}
+	for _, cmd := range cliCommands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout)
+		}
+	}
+	if args[0] == "help" {
+		usage(stdout)
+		return nil
+	}
+	usage(stdout)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func newFlagSet(name string, stdout io.Writer) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	configFile := flags.String("config", defaultConfigFile, "path to the config file")
+	return flags, configFile
+}
+
+func runServe(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("serve", stdout)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if config.WebhookSecret == "" {
+		return errWebhookSecretNotSet
+	}
+	store, err := openStore(config.StorePath)
+	if err != nil {
+		return fmt.Errorf("opening store %s: %w", config.StorePath, err)
+	}
+	app := newApp(config, store)
+	app.use(recoverMiddleware, loggingMiddleware, app.metricsMiddleware, newDedupMiddleware(1000))
+	app.registerRoutes()
+	app.startLinkChecker(context.Background())
+	app.startOrderExpiry(context.Background())
+	app.startScheduler(context.Background())
+	app.resumeBroadcasts()
+	return app.startServer()
+}
+
+func runSetWebhook(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("set-webhook", stdout)
+	url := flags.String("url", "", "public URL of the /bot endpoint")
+	dropPending := flags.Bool("drop-pending", false, "drop updates that arrived while no webhook was set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *url == "" {
+		return errors.New("-url is required")
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if config.WebhookSecret == "" {
+		return errWebhookSecretNotSet
+	}
+	client := newTelegramClient(config.ApiUrl, config.Token)
+	if err := client.setWebhook(*url, allowedUpdates, *dropPending, config.WebhookSecret); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Webhook set to %s\n", *url)
+	return nil
+}
+
+func runDeleteWebhook(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("delete-webhook", stdout)
+	dropPending := flags.Bool("drop-pending", false, "drop pending updates")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	client := newTelegramClient(config.ApiUrl, config.Token)
+	if err := client.deleteWebhook(*dropPending); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Webhook deleted")
+	return nil
+}
+
+func runWebhookInfo(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("webhook-info", stdout)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	client := newTelegramClient(config.ApiUrl, config.Token)
+	info, err := client.getWebhookInfo()
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(output))
+	return nil
+}
+
+func runValidateConfig(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("validate-config", stdout)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := loadConfig(*configFile); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	fmt.Fprintf(stdout, "%s is valid\n", *configFile)
+	return nil
+}
+
+func runSendTest(args []string, stdout io.Writer) error {
+	flags, configFile := newFlagSet("send-test", stdout)
+	chatID := flags.Int64("chat", 0, "chat to send the welcome message to")
+	threadID := flags.Int64("thread", 0, "forum topic to send the welcome message to")
+	name := flags.String("name", "Тест", "first name of the greeted member")
+	username := flags.String("username", "", "username of the greeted member")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *chatID == 0 {
+		return errors.New("-chat is required")
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Send through the regular payload builders with the target chat swapped in
+	testConfig := *config
+	testConfig.ChatID = *chatID
+	testConfig.ThreadID = *threadID
+	app := newApp(&testConfig, newMemoryStore())
+
+	member := User{ID: *chatID, FirstName: *name, Username: *username}
+	if err := app.sendNewMembersMessage([]User{member}); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Welcome message sent to %d\n", *chatID)
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testConfigFile = `
+[links]
+distillate = "https://example.com/distillate"
+prices = "https://example.com/prices"
+soap = "https://example.com/soap"
+ubtan = "https://example.com/ubtan"
+`
+
+func setupCliTest(t *testing.T) (*fakeTelegramApi, string) {
+	api := newFakeTelegramApi(t)
+	t.Setenv("TOKEN", "test_token")
+	t.Setenv("CHAT_ID", "123456789")
+	t.Setenv("API_URL", api.server.URL)
+	t.Setenv("WEBHOOK_SECRET", "test_secret")
+	return api, writeTestConfigFile(t, testConfigFile)
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout bytes.Buffer
+
+	err := run([]string{"restart"}, &stdout)
+	if err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Expected unknown command error, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "validate-config") {
+		t.Error("Expected usage to list the commands")
+	}
+}
+
+func TestRunValidateConfig(t *testing.T) {
+	_, configFile := setupCliTest(t)
+	var stdout bytes.Buffer
+
+	if err := run([]string{"validate-config", "-config", configFile}, &stdout); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "is valid") {
+		t.Errorf("Expected success message, got %s", stdout.String())
+	}
+}
+
+func TestRunValidateConfigInvalid(t *testing.T) {
+	_, configFile := setupCliTest(t)
+	t.Setenv("CHAT_ID", "0")
+
+	err := run([]string{"validate-config", "-config", configFile}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "CHAT_ID is not set") {
+		t.Errorf("Expected CHAT_ID error, got %v", err)
+	}
+}
+
+func TestRunSetWebhook(t *testing.T) {
+	api, configFile := setupCliTest(t)
+
+	err := run([]string{"set-webhook", "-config", configFile, "-url", "https://bot.example.com/bot"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("setWebhook")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 setWebhook call, got %d", len(calls))
+	}
+	if calls[0].Params["url"] != "https://bot.example.com/bot" {
+		t.Errorf("Expected webhook url, got %v", calls[0].Params["url"])
+	}
+	if len(calls[0].Params["allowed_updates"].([]any)) != len(allowedUpdates) {
+		t.Errorf("Expected allowed updates %v, got %v", allowedUpdates, calls[0].Params["allowed_updates"])
+	}
+	if calls[0].Params["secret_token"] != "test_secret" {
+		t.Errorf("Expected the webhook secret, got %v", calls[0].Params["secret_token"])
+	}
+}
+
+func TestRunSetWebhookWithoutSecret(t *testing.T) {
+	api, configFile := setupCliTest(t)
+	t.Setenv("WEBHOOK_SECRET", "")
+
+	err := run([]string{"set-webhook", "-config", configFile, "-url", "https://bot.example.com/bot"}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "WEBHOOK_SECRET") {
+		t.Errorf("Expected WEBHOOK_SECRET error, got %v", err)
+	}
+	if len(api.callsTo("setWebhook")) != 0 {
+		t.Error("Expected the webhook not to be registered")
+	}
+}
+
+func TestRunSetWebhookWithoutUrl(t *testing.T) {
+	_, configFile := setupCliTest(t)
+
+	if err := run([]string{"set-webhook", "-config", configFile}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error without -url")
+	}
+}
+
+func TestRunDeleteWebhook(t *testing.T) {
+	api, configFile := setupCliTest(t)
+
+	if err := run([]string{"delete-webhook", "-config", configFile}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(api.callsTo("deleteWebhook")) != 1 {
+		t.Error("Expected deleteWebhook to be called")
+	}
+}
+
+func TestRunWebhookInfo(t *testing.T) {
+	api, configFile := setupCliTest(t)
+	api.setResponse("getWebhookInfo", `{"ok": true, "result": {"url": "https://bot.example.com/bot", "pending_update_count": 3}}`)
+	var stdout bytes.Buffer
+
+	if err := run([]string{"webhook-info", "-config", configFile}, &stdout); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"pending_update_count": 3`) {
+		t.Errorf("Expected webhook info in output, got %s", stdout.String())
+	}
+}
+
+func TestRunSendTest(t *testing.T) {
+	api, configFile := setupCliTest(t)
+
+	err := run([]string{"send-test", "-config", configFile, "-chat", "42", "-username", "tester"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 sendMessage call, got %d", len(calls))
+	}
+	if calls[0].Params["chat_id"] != float64(42) {
+		t.Errorf("Expected chat_id 42, got %v", calls[0].Params["chat_id"])
+	}
+	if !strings.Contains(calls[0].Params["text"].(string), "@tester") {
+		t.Errorf("Expected welcome for @tester, got %s", calls[0].Params["text"])
+	}
+}
+
+func TestRunSendTestWithoutChat(t *testing.T) {
+	_, configFile := setupCliTest(t)
+
+	if err := run([]string{"send-test", "-config", configFile}, &bytes.Buffer{}); err == nil {
+		t.Error("Expected error without -chat")
+	}
+}
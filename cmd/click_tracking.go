@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clickHistoryLimit caps the stored clicks, older ones are dropped first,
+	// the totals per button are kept separately and never capped
+	clickHistoryLimit = 10000
+	// clickDedupWindow counts repeated clicks on a button from the same chat once
+	clickDedupWindow = 10 * time.Second
+)
+
+type ButtonClick struct {
+	Button    string    `json:"button"`
+	ChatID    int64     `json:"chat_id"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+func linkButtons(links *Links) []Button {
+	return []Button{
+		{ID: "prices", Text: "Как сделать заказ", Url: links.Prices},
+		{ID: "soap", Text: "Что такое крафтовое мыло", Url: links.Soap},
+		{ID: "distillate", Text: "Что такое гидролат", Url: links.Distillate},
+		{ID: "ubtan", Text: "Что такое убтан", Url: links.Ubtan},
+	}
+}
+
+// trackedButtonUrls maps button ids to their real links, only these ids are redirected
+func (app *App) trackedButtonUrls() map[string]string {
+	urls := make(map[string]string)
+	for _, button := range app.configuredButtons() {
+		urls[button.ID] = button.Url
+	}
+	return urls
+}
+
+func buildTrackingUrl(baseUrl string, buttonID string, chatID int64) string {
+	return fmt.Sprintf("%s/r/%s?chat=%d", strings.TrimSuffix(baseUrl, "/"), url.PathEscape(buttonID), chatID)
+}
+
+// trackButtons points buttons with an id at the redirect endpoint when tracking is enabled
+func (app *App) trackButtons(buttons []Button, chatID int64) []Button {
+	if !app.config.Tracking.Enabled {
+		return buttons
+	}
+	tracked := make([]Button, len(buttons))
+	for i, button := range buttons {
+		tracked[i] = button
+		if button.ID != "" {
+			tracked[i].Url = buildTrackingUrl(app.config.Tracking.BaseUrl, button.ID, chatID)
+		}
+	}
+	return tracked
+}
+
+// clickBuffer keeps clicks in memory until the scheduler flushes them to the store,
+// so the public redirect endpoint never writes the store itself
+type clickBuffer struct {
+	mu      sync.Mutex
+	pending []ButtonClick
+	// seen holds the last counted click by button and chat
+	seen map[string]time.Time
+}
+
+func newClickBuffer() *clickBuffer {
+	return &clickBuffer{seen: make(map[string]time.Time)}
+}
+
+// add reports whether the click was counted
+func (b *clickBuffer) add(click ButtonClick) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := fmt.Sprintf("%s:%d", click.Button, click.ChatID)
+	if last, ok := b.seen[key]; ok && click.ClickedAt.Sub(last) < clickDedupWindow {
+		return false
+	}
+	b.seen[key] = click.ClickedAt
+	b.pending = append(b.pending, click)
+	if len(b.pending) > clickHistoryLimit {
+		b.pending = b.pending[len(b.pending)-clickHistoryLimit:]
+	}
+	return true
+}
+
+// take empties the buffer and forgets the clicks that are out of the dedup window
+func (b *clickBuffer) take(now time.Time) []ButtonClick {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, last := range b.seen {
+		if now.Sub(last) >= clickDedupWindow {
+			delete(b.seen, key)
+		}
+	}
+	pending := b.pending
+	b.pending = nil
+	return pending
+}
+
+func (app *App) recordClick(buttonID string, chatID int64, now time.Time) {
+	if app.clicks.add(ButtonClick{Button: buttonID, ChatID: chatID, ClickedAt: now}) {
+		app.metrics.buttonClicks.inc(buttonID)
+	}
+}
+
+// flushClicks saves the buffered clicks in one store update
+func (app *App) flushClicks(now time.Time) {
+	clicks := app.clicks.take(now)
+	if len(clicks) == 0 {
+		return
+	}
+	err := app.store.update(func(data *storeData) {
+		for _, click := range clicks {
+			data.ButtonClickTotals[click.Button]++
+		}
+		data.ButtonClicks = append(data.ButtonClicks, clicks...)
+		if len(data.ButtonClicks) > clickHistoryLimit {
+			data.ButtonClicks = data.ButtonClicks[len(data.ButtonClicks)-clickHistoryLimit:]
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving button clicks", "clicks", len(clicks), "error", err)
+	}
+}
+
+func (app *App) redirectHandler(w http.ResponseWriter, r *http.Request) {
+	buttonID := r.PathValue("id")
+	target, ok := app.trackedButtonUrls()[buttonID]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	chatID, _ := strconv.ParseInt(r.URL.Query().Get("chat"), 10, 64)
+	app.recordClick(buttonID, chatID, time.Now())
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func countClicks(clicks []ButtonClick) map[string]int {
+	counts := make(map[string]int)
+	for _, click := range clicks {
+		counts[click.Button]++
+	}
+	return counts
+}
+
+func createClicksReport(counts map[string]int, buttons []Button) string {
+	if len(counts) == 0 {
+		return "Переходов по кнопкам пока не было."
+	}
+
+	// Known buttons first in keyboard order, then ids that are no longer configured
+	var lines []string
+	listed := make(map[string]bool)
+	for _, button := range buttons {
+		if listed[button.ID] {
+			continue
+		}
+		listed[button.ID] = true
+		lines = append(lines, fmt.Sprintf("%s: %d", button.Text, counts[button.ID]))
+	}
+	var removed []string
+	for id := range counts {
+		if !listed[id] {
+			removed = append(removed, id)
+		}
+	}
+	slices.Sort(removed)
+	for _, id := range removed {
+		lines = append(lines, fmt.Sprintf("%s: %d", id, counts[id]))
+	}
+	return "Переходы по кнопкам:\n\n" + strings.Join(lines, "\n")
+}
+
+// buttons lists every button in the config, starting with the ones for links
+func (c *Config) buttons(links *Links) []Button {
+	buttons := linkButtons(links)
+	for _, topic := range c.Welcome.Topics {
+		buttons = append(buttons, topic.Buttons...)
+	}
+	for _, entry := range c.Faq.Entries {
+		buttons = append(buttons, entry.Buttons...)
+	}
+	for _, post := range c.Schedule {
+		buttons = append(buttons, post.Buttons...)
+	}
+	for _, campaign := range c.Campaigns {
+		buttons = append(buttons, campaign.Buttons...)
+	}
+	for _, step := range c.Onboarding.Steps {
+		buttons = append(buttons, step.Buttons...)
+	}
+	return buttons
+}
+
+// allButtons lists every configured button with the current links, with or without an id
+func (app *App) allButtons() []Button {
+	links := app.links()
+	return app.config.buttons(&links)
+}
+
+// configuredButtons are the buttons with an id, only they are tracked
+func (app *App) configuredButtons() []Button {
+	var buttons []Button
+	for _, button := range app.allButtons() {
+		if button.ID != "" {
+			buttons = append(buttons, button)
+		}
+	}
+	return buttons
+}
+
+func (app *App) handleClicksCommand(message *Message, args string) {
+	app.flushClicks(time.Now())
+	var counts map[string]int
+	app.store.view(func(data *storeData) {
+		counts = maps.Clone(data.ButtonClickTotals)
+	})
+	app.replyText(message, createClicksReport(counts, app.configuredButtons()))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func trackingTestConfig(config *Config) {
+	config.Tracking = Tracking{Enabled: true, BaseUrl: "https://bot.example.com/"}
+}
+
+func TestBuildTrackingUrl(t *testing.T) {
+	result := buildTrackingUrl("https://bot.example.com/", "prices", -100123)
+	expected := "https://bot.example.com/r/prices?chat=-100123"
+	if result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestTrackButtons(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+	buttons := []Button{
+		{ID: "prices", Text: "Прайс", Url: "https://example.com/prices"},
+		{Text: "Без отслеживания", Url: "https://example.com/other"},
+	}
+
+	tracked := app.trackButtons(buttons, 123456789)
+	if tracked[0].Url != "https://bot.example.com/r/prices?chat=123456789" {
+		t.Errorf("Expected tracking url, got %s", tracked[0].Url)
+	}
+	if tracked[1].Url != "https://example.com/other" {
+		t.Errorf("Expected button without id to keep its url, got %s", tracked[1].Url)
+	}
+	if buttons[0].Url != "https://example.com/prices" {
+		t.Error("Expected original buttons to be unchanged")
+	}
+
+	app.config.Tracking.Enabled = false
+	if untracked := app.trackButtons(buttons, 123456789); untracked[0].Url != "https://example.com/prices" {
+		t.Errorf("Expected direct url when tracking is disabled, got %s", untracked[0].Url)
+	}
+}
+
+func TestWelcomeUsesTrackingUrls(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+
+	payload := app.newMembersMessageParams(app.defaultWelcomeTopic(), testWelcomeMembers)
+
+	keyboard := payload["reply_markup"].(map[string]any)["inline_keyboard"].([][]map[string]string)
+	if keyboard[0][0]["url"] != "https://bot.example.com/r/prices?chat=123456789" {
+		t.Errorf("Expected tracked prices button, got %s", keyboard[0][0]["url"])
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /r/{id}", app.redirectHandler)
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "known button",
+			path:             "/r/ubtan?chat=123456789",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.com/ubtan",
+		},
+		{
+			name:           "unknown button",
+			path:           "/r/evil?chat=123456789",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if location := rr.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("Expected location %q, got %q", tt.expectedLocation, location)
+			}
+		})
+	}
+
+	app.flushClicks(time.Now())
+	app.store.view(func(data *storeData) {
+		if len(data.ButtonClicks) != 1 {
+			t.Fatalf("Expected 1 recorded click, got %d", len(data.ButtonClicks))
+		}
+		click := data.ButtonClicks[0]
+		if click.Button != "ubtan" || click.ChatID != 123456789 || click.ClickedAt.IsZero() {
+			t.Errorf("Unexpected click %+v", click)
+		}
+	})
+	if app.metrics.buttonClicks.value("ubtan") != 1 {
+		t.Errorf("Expected click metric 1, got %d", app.metrics.buttonClicks.value("ubtan"))
+	}
+}
+
+func TestCreateClicksReport(t *testing.T) {
+	buttons := linkButtons(&Links{})
+	clicks := []ButtonClick{
+		{Button: "prices"},
+		{Button: "prices"},
+		{Button: "ubtan"},
+		{Button: "removed"},
+	}
+
+	report := createClicksReport(countClicks(clicks), buttons)
+
+	for _, expected := range []string{"Как сделать заказ: 2", "Что такое убтан: 1", "Что такое гидролат: 0", "removed: 1"} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("Expected report to contain %q, got %s", expected, report)
+		}
+	}
+	if createClicksReport(map[string]int{}, buttons) != "Переходов по кнопкам пока не было." {
+		t.Error("Expected empty report")
+	}
+}
+
+func TestClicksCommandOnlyInAdminChat(t *testing.T) {
+	app, api := newTestApp(t, trackingTestConfig)
+	app.recordClick("soap", 123456789, time.Now())
+
+	app.handleTelegramUpdate(createTestCommand("/clicks"))
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("Expected /clicks to be ignored outside the admin chat")
+	}
+
+	app.handleTelegramUpdate(&Update{Message: &Message{Text: "/clicks", Chat: Chat{ID: 555}}})
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(calls))
+	}
+	if !strings.Contains(calls[0].Params["text"].(string), "Что такое крафтовое мыло: 1") {
+		t.Errorf("Expected soap click in report, got %s", calls[0].Params["text"])
+	}
+}
+
+func TestClickHistoryLimit(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+	app.store.update(func(data *storeData) {
+		data.ButtonClicks = slices.Repeat([]ButtonClick{{Button: "soap"}}, clickHistoryLimit)
+		data.ButtonClickTotals["soap"] = clickHistoryLimit + 500
+	})
+
+	app.recordClick("soap", 1, time.Now())
+	app.flushClicks(time.Now())
+
+	app.store.view(func(data *storeData) {
+		if len(data.ButtonClicks) != clickHistoryLimit {
+			t.Errorf("Expected %d clicks, got %d", clickHistoryLimit, len(data.ButtonClicks))
+		}
+		if data.ButtonClicks[len(data.ButtonClicks)-1].ChatID != 1 {
+			t.Error("Expected the newest click to be kept")
+		}
+		if data.ButtonClickTotals["soap"] != clickHistoryLimit+501 {
+			t.Errorf("Expected the total to keep counting past the history limit, got %d", data.ButtonClickTotals["soap"])
+		}
+	})
+}
+
+func TestRecordClickDedup(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	app.recordClick("soap", 1, now)
+	app.recordClick("soap", 1, now.Add(time.Second))
+	app.recordClick("soap", 2, now.Add(time.Second))
+	app.recordClick("prices", 1, now.Add(time.Second))
+	app.flushClicks(now.Add(time.Second))
+	app.recordClick("soap", 1, now.Add(clickDedupWindow))
+	app.flushClicks(now.Add(clickDedupWindow))
+
+	app.store.view(func(data *storeData) {
+		if counts := data.ButtonClickTotals; counts["soap"] != 3 || counts["prices"] != 1 {
+			t.Errorf("Expected repeated clicks from a chat within the window to count once, got %v", counts)
+		}
+	})
+	if app.metrics.buttonClicks.value("soap") != 3 {
+		t.Errorf("Expected click metric 3, got %d", app.metrics.buttonClicks.value("soap"))
+	}
+}
+
+func TestConfiguredButtons(t *testing.T) {
+	app, _ := newTestApp(t, trackingTestConfig)
+	app.config.Faq.Entries = []FaqEntry{{Buttons: []Button{{ID: "delivery", Url: "https://example.com/delivery"}, {Url: "https://example.com/other"}}}}
+	app.config.Campaigns = []Campaign{{ID: "fair", Buttons: []Button{{ID: "fair_map", Url: "https://example.com/map"}}}}
+
+	urls := app.trackedButtonUrls()
+	if urls["delivery"] != "https://example.com/delivery" || urls["fair_map"] != "https://example.com/map" || len(urls) != 6 {
+		t.Errorf("Expected every button with an id to be tracked, got %v", urls)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMessageLength is the most characters Telegram accepts in one message
+const maxMessageLength = 4096
+
+type CommandHandler func(message *Message, args string)
+
+type command struct {
+	description string
+	handler     CommandHandler
+}
+
+type CommandRouter struct {
+	botUsername string
+	commands    map[string]command
+	order       []string
+}
+
+func newCommandRouter(botUsername string) *CommandRouter {
+	return &CommandRouter{
+		botUsername: botUsername,
+		commands:    make(map[string]command),
+	}
+}
+
+func (r *CommandRouter) handle(name string, description string, handler CommandHandler) {
+	name = strings.TrimPrefix(name, "/")
+	if !slices.Contains(r.order, name) {
+		r.order = append(r.order, name)
+	}
+	r.commands[name] = command{description: description, handler: handler}
+}
+
+// parseCommand splits "/start@soapmama_bot payload" into its parts
+func parseCommand(text string) (name string, botUsername string, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", "", false
+	}
+	head, args, _ := strings.Cut(text[1:], " ")
+	name, botUsername, _ = strings.Cut(head, "@")
+	if name == "" {
+		return "", "", "", false
+	}
+	return strings.ToLower(name), botUsername, strings.TrimSpace(args), true
+}
+
+func isCommand(message *Message) bool {
+	return message != nil && strings.HasPrefix(message.Text, "/")
+}
+
+// dispatch runs the handler for the command in message and reports whether there was one.
+// Commands addressed to other bots are ignored.
+func (r *CommandRouter) dispatch(message *Message) bool {
+	name, botUsername, args, ok := parseCommand(message.Text)
+	if !ok {
+		return false
+	}
+	if botUsername != "" && !strings.EqualFold(botUsername, r.botUsername) {
+		return false
+	}
+	cmd, ok := r.commands[name]
+	if !ok {
+		return false
+	}
+	cmd.handler(message, args)
+	return true
+}
+
+func (r *CommandRouter) help() string {
+	var lines []string
+	for _, name := range r.order {
+		if description := r.commands[name].description; description != "" {
+			lines = append(lines, "/"+name+" — "+description)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) registerCommands() {
+	app.commands.handle("help", "список команд", app.handleHelpCommand)
+	app.commands.handle("links", "полезные ссылки", app.handleLinksCommand)
+	app.commands.handle("catalog", "каталог товаров", app.handleCatalogCommand)
+	if app.config.Orders.Enabled {
+		app.commands.handle("order", "оформить заказ", app.handleOrderCommand)
+		app.commands.handle("cancel", "отменить оформление заказа", app.handleCancelCommand)
+		app.commands.handle("myorders", "мои заказы", app.handleMyOrdersCommand)
+	}
+	app.commands.handle("subscribe", "подписаться на новости", app.handleSubscribeCommand)
+	app.commands.handle("unsubscribe", "отписаться от новостей", app.handleUnsubscribeCommand)
+	if app.config.Onboarding.Enabled {
+		app.commands.handle("stop", "не присылать советы", app.handleStopCommand)
+	}
+	// /start is sent by Telegram when a private chat is opened, it has no description to keep /help short
+	app.commands.handle("start", "", app.handleStartCommand)
+	app.commands.handle("clicks", "", app.adminOnly(app.handleClicksCommand))
+	app.commands.handle("campaigns", "", app.adminOnly(app.handleCampaignsCommand))
+	app.commands.handle("invite", "", app.adminOnly(app.handleInviteCommand))
+	app.commands.handle("invites", "", app.adminOnly(app.handleInvitesCommand))
+	app.commands.handle("revoke", "", app.adminOnly(app.handleRevokeCommand))
+	app.commands.handle("publish", "", app.adminOnly(app.handlePublishCommand))
+	app.commands.handle("schedule", "", app.adminPrivateOnly(app.handleScheduleCommand))
+	app.commands.handle("scheduled", "", app.adminPrivateOnly(app.handleScheduledCommand))
+	app.commands.handle("preview", "", app.adminPrivateOnly(app.handlePreviewCommand))
+	app.commands.handle("unschedule", "", app.adminPrivateOnly(app.handleUnscheduleCommand))
+	app.commands.handle("broadcast", "", app.adminPrivateOnly(app.handleBroadcastCommand))
+}
+
+func (app *App) isAdminChat(chatID int64) bool {
+	return app.config.AdminChatID != 0 && chatID == app.config.AdminChatID
+}
+
+// adminOnly restricts a command to the admin chat, admin commands have no description
+// so they don't show up in /help
+func (app *App) adminOnly(handler CommandHandler) CommandHandler {
+	return func(message *Message, args string) {
+		if app.isAdminChat(message.Chat.ID) {
+			handler(message, args)
+		}
+	}
+}
+
+// deepLink opens the private chat with the bot and sends /start payload, it's empty without BOT_USERNAME
+func (app *App) deepLink(payload string) string {
+	if app.config.BotUsername == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s", app.config.BotUsername, payload)
+}
+
+// handleStartCommand handles deep links like t.me/soapmama_bot?start=order-lavender-soap
+func (app *App) handleStartCommand(message *Message, args string) {
+	greeting := "Здравствуйте! Я бот мастерской «Мыльная Мама».\n\n" + app.commands.help()
+	if message.Chat.Type != "private" {
+		app.replyText(message, greeting)
+		return
+	}
+
+	now := time.Now()
+	payload, productID, _ := strings.Cut(args, "-")
+	replied := true
+	switch {
+	case args == subscribeStartPayload:
+		app.handleSubscribeCommand(message, "")
+	case args == welcomeStartPayload:
+		app.handleWelcomeStart(message)
+	case app.config.Orders.Enabled && payload == orderStartPayload:
+		app.startOrder(message.From.ID, productID, now)
+	default:
+		replied = args != "" && app.handleCampaignStart(message, args)
+	}
+
+	// Every new user enters onboarding whatever link brought them. Its first step greets them
+	// instead of the list of commands, unless the link has already been answered.
+	if app.startOnboarding(message.From, now, replied) || replied {
+		return
+	}
+	app.replyText(message, greeting)
+}
+
+func (app *App) handleHelpCommand(message *Message, args string) {
+	app.replyText(message, app.commands.help())
+}
+
+func (app *App) handleLinksCommand(message *Message, args string) {
+	links := app.links()
+	app.reply(message, map[string]any{
+		"text":         "Полезные ссылки о мастерской «Мыльная Мама»:",
+		"reply_markup": createCustomButtonsMarkup(app.trackButtons(linkButtons(&links), message.Chat.ID)),
+	})
+}
+
+// replyThreadID returns the forum topic of message, messages in the General topic
+// and in regular groups have no topic to reply to
+func replyThreadID(message *Message) int64 {
+	if message.IsTopicMessage {
+		return message.MessageThreadID
+	}
+	return 0
+}
+
+func (app *App) reply(message *Message, params map[string]any) {
+	params["chat_id"] = message.Chat.ID
+	setMessageThreadID(params, replyThreadID(message))
+	if _, err := app.telegram.sendMessage(params); err != nil {
+		slog.Error("Error sending reply", "chat_id", message.Chat.ID, "error", err)
+	}
+}
+
+func (app *App) replyText(message *Message, text string) {
+	app.reply(message, map[string]any{"text": text})
+}
+
+// splitMessage joins lines into as few texts as fit in a message each
+func splitMessage(lines []string) []string {
+	var texts []string
+	var current []string
+	length := 0
+	for _, line := range lines {
+		lineLength := utf8.RuneCountInString(line)
+		if len(current) > 0 && length+1+lineLength > maxMessageLength {
+			texts = append(texts, strings.Join(current, "\n"))
+			current, length = nil, 0
+		}
+		if len(current) > 0 {
+			length++
+		}
+		current = append(current, line)
+		length += lineLength
+	}
+	if len(current) > 0 {
+		texts = append(texts, strings.Join(current, "\n"))
+	}
+	return texts
+}
+
+// replyLines sends a long report in as many messages as it takes
+func (app *App) replyLines(message *Message, lines []string) {
+	for _, text := range splitMessage(lines) {
+		app.replyText(message, text)
+	}
+}
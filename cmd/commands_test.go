@@ -0,0 +1,184 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func createTestCommand(text string) *Update {
+	return &Update{
+		Message: &Message{
+			Text: text,
+			Chat: Chat{ID: 123456789, Type: "supergroup"},
+			From: User{ID: 111222333, FirstName: "Jane"},
+		},
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name                string
+		text                string
+		expectedName        string
+		expectedBotUsername string
+		expectedArgs        string
+		expectedOk          bool
+	}{
+		{
+			name:         "plain command",
+			text:         "/help",
+			expectedName: "help",
+			expectedOk:   true,
+		},
+		{
+			name:                "command with bot username and args",
+			text:                "/Start@soapmama_bot  instagram_oct ",
+			expectedName:        "start",
+			expectedBotUsername: "soapmama_bot",
+			expectedArgs:        "instagram_oct",
+			expectedOk:          true,
+		},
+		{
+			name: "regular text",
+			text: "Hello world",
+		},
+		{
+			name: "slash only",
+			text: "/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, botUsername, args, ok := parseCommand(tt.text)
+			if name != tt.expectedName || botUsername != tt.expectedBotUsername || args != tt.expectedArgs || ok != tt.expectedOk {
+				t.Errorf("Expected (%s, %s, %s, %v), got (%s, %s, %s, %v)",
+					tt.expectedName, tt.expectedBotUsername, tt.expectedArgs, tt.expectedOk,
+					name, botUsername, args, ok)
+			}
+		})
+	}
+}
+
+func TestCommandRouterDispatch(t *testing.T) {
+	router := newCommandRouter("soapmama_bot")
+	var received []string
+	router.handle("/echo", "", func(message *Message, args string) {
+		received = append(received, args)
+	})
+
+	tests := []struct {
+		name     string
+		text     string
+		expected bool
+	}{
+		{name: "registered command", text: "/echo one", expected: true},
+		{name: "addressed to this bot", text: "/echo@SoapMama_Bot two", expected: true},
+		{name: "addressed to another bot", text: "/echo@other_bot three", expected: false},
+		{name: "unknown command", text: "/unknown", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := router.dispatch(&Message{Text: tt.text})
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	if strings.Join(received, ",") != "one,two" {
+		t.Errorf("Expected args one,two, got %v", received)
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	app, api := newTestApp(t, nil)
+
+	app.handleTelegramUpdate(createTestCommand("/help"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 reply, got %d", len(calls))
+	}
+	if !strings.Contains(calls[0].Params["text"].(string), "/links") {
+		t.Errorf("Expected help to list /links, got %s", calls[0].Params["text"])
+	}
+}
+
+func TestCommandRepliesInTopic(t *testing.T) {
+	tests := []struct {
+		name           string
+		message        Message
+		expectedThread any
+	}{
+		{
+			name:           "forum topic",
+			message:        Message{Text: "/links", Chat: Chat{ID: 123456789}, MessageThreadID: 7, IsTopicMessage: true},
+			expectedThread: float64(7),
+		},
+		{
+			name:           "general topic",
+			message:        Message{Text: "/links", Chat: Chat{ID: 123456789}},
+			expectedThread: nil,
+		},
+		{
+			name:           "reply thread outside of a forum",
+			message:        Message{Text: "/links", Chat: Chat{ID: 123456789}, MessageThreadID: 9},
+			expectedThread: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, api := newTestApp(t, nil)
+
+			app.handleTelegramUpdate(&Update{Message: &tt.message})
+
+			calls := api.callsTo("sendMessage")
+			if len(calls) != 1 {
+				t.Fatalf("Expected 1 reply, got %d", len(calls))
+			}
+			if calls[0].Params["message_thread_id"] != tt.expectedThread {
+				t.Errorf("Expected message_thread_id %v, got %v", tt.expectedThread, calls[0].Params["message_thread_id"])
+			}
+			if calls[0].Params["reply_markup"] == nil {
+				t.Error("Expected links keyboard")
+			}
+		})
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	long := strings.Repeat("я", 3000)
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			name:     "fits in one message",
+			lines:    []string{"Кампании:", "", "fair_oct: 1"},
+			expected: []string{"Кампании:\n\nfair_oct: 1"},
+		},
+		{
+			name:     "splits between lines",
+			lines:    []string{"Кампании:", long, long},
+			expected: []string{"Кампании:\n" + long, long},
+		},
+		{
+			name:     "exactly the limit",
+			lines:    []string{strings.Repeat("я", maxMessageLength-2), "я"},
+			expected: []string{strings.Repeat("я", maxMessageLength-2) + "\nя"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := splitMessage(tt.lines); !slices.Equal(result, tt.expected) {
+				t.Errorf("Expected %d messages, got %d", len(tt.expected), len(result))
+			}
+		})
+	}
+}
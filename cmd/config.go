@@ -1,12 +1,23 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
 )
 
+// webhookSecretPattern is what Telegram accepts as a webhook secret_token
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 type Links struct {
 	Distillate string `mapstructure:"distillate"`
 	Prices     string `mapstructure:"prices"`
@@ -14,50 +25,524 @@ type Links struct {
 	Ubtan      string `mapstructure:"ubtan"`
 }
 
+type JoinRequests struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Challenge string   `mapstructure:"challenge"`
+	Question  string   `mapstructure:"question"`
+	Answers   []string `mapstructure:"answers"`
+	Review    string   `mapstructure:"review"`
+}
+
+type Bots struct {
+	Greet            bool     `mapstructure:"greet"`
+	Allowed          []string `mapstructure:"allowed"`
+	KickUnauthorized bool     `mapstructure:"kick_unauthorized"`
+}
+
+type ReturningMembers struct {
+	Cooldown time.Duration `mapstructure:"cooldown"`
+	Mode     string        `mapstructure:"mode"`
+	Template string        `mapstructure:"template"`
+}
+
+type WelcomeMedia struct {
+	File   string `mapstructure:"file"`
+	FileID string `mapstructure:"file_id"`
+}
+
+type Button struct {
+	ID   string `mapstructure:"id" json:"id,omitempty"`
+	Text string `mapstructure:"text" json:"text"`
+	Url  string `mapstructure:"url" json:"url"`
+}
+
+type Tracking struct {
+	Enabled bool   `mapstructure:"enabled"`
+	BaseUrl string `mapstructure:"base_url"`
+}
+
+type WelcomeTopic struct {
+	ID       int64    `mapstructure:"id"`
+	Name     string   `mapstructure:"name"`
+	Template string   `mapstructure:"template"`
+	Buttons  []Button `mapstructure:"buttons"`
+}
+
+type Welcome struct {
+	Media  []WelcomeMedia `mapstructure:"media"`
+	Topics []WelcomeTopic `mapstructure:"topics"`
+	// Private sends the welcome to newcomers in private chat, the group welcome is left
+	// for those who haven't started the bot
+	Private bool `mapstructure:"private"`
+	// Gift follows the private welcome, e.g. a promo code, the group welcome then promises a gift
+	Gift string `mapstructure:"gift"`
+}
+
+type LinkCheck struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Failures int           `mapstructure:"failures"`
+}
+
+type Telegraph struct {
+	ApiUrl      string `mapstructure:"api_url"`
+	AccessToken string `mapstructure:"access_token"`
+	AuthorName  string `mapstructure:"author_name"`
+	ContentDir  string `mapstructure:"content_dir"`
+}
+
+// Product is a catalog item, the id is used in callback data so it must be short and stable
+type Product struct {
+	ID          string   `mapstructure:"id"`
+	Name        string   `mapstructure:"name"`
+	Category    string   `mapstructure:"category"`
+	Price       int      `mapstructure:"price"`
+	Description string   `mapstructure:"description"`
+	Photo       string   `mapstructure:"photo"`
+	Ingredients []string `mapstructure:"ingredients"`
+	InStock     bool     `mapstructure:"in_stock"`
+}
+
+type Orders struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Delivery []string      `mapstructure:"delivery"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// FaqEntry is answered when a group message contains one of the trigger phrases, in any word form,
+// or matches one of the patterns
+type FaqEntry struct {
+	Triggers []string `mapstructure:"triggers"`
+	Patterns []string `mapstructure:"patterns"`
+	Answer   string   `mapstructure:"answer"`
+	Buttons  []Button `mapstructure:"buttons"`
+}
+
+type Faq struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Cooldown time.Duration `mapstructure:"cooldown"`
+	Entries  []FaqEntry    `mapstructure:"entries"`
+}
+
+// ScheduledPost is posted whenever its cron expression matches in its timezone,
+// to CHAT_ID and THREAD_ID unless chat_id is set
+type ScheduledPost struct {
+	ID       string   `mapstructure:"id"`
+	Cron     string   `mapstructure:"cron"`
+	Timezone string   `mapstructure:"timezone"`
+	ChatID   int64    `mapstructure:"chat_id"`
+	ThreadID int64    `mapstructure:"thread_id"`
+	Template string   `mapstructure:"template"`
+	Buttons  []Button `mapstructure:"buttons"`
+}
+
+// Announcements are scheduled by admins with /schedule, dates are read in timezone
+type Announcements struct {
+	Timezone string `mapstructure:"timezone"`
+}
+
+// Subscriptions.Rate is how many /broadcast messages are sent per second by all broadcasts together, Telegram allows about 30
+type Subscriptions struct {
+	Rate int `mapstructure:"rate"`
+}
+
+// OnboardingStep is sent delay after the user started the bot, catalog adds the /catalog menu after the text
+type OnboardingStep struct {
+	Delay   time.Duration `mapstructure:"delay"`
+	Text    string        `mapstructure:"text"`
+	Buttons []Button      `mapstructure:"buttons"`
+	Catalog bool          `mapstructure:"catalog"`
+}
+
+// Onboarding is the sequence of messages to users who started the bot, it ends with their first order or /stop
+type Onboarding struct {
+	Enabled bool             `mapstructure:"enabled"`
+	Steps   []OnboardingStep `mapstructure:"steps"`
+}
+
+// Campaign is a /start payload shared in deep links like t.me/soapmama_bot?start=instagram_oct,
+// users who come from it get the greeting
+type Campaign struct {
+	ID       string   `mapstructure:"id"`
+	Name     string   `mapstructure:"name"`
+	Greeting string   `mapstructure:"greeting"`
+	Buttons  []Button `mapstructure:"buttons"`
+}
+
 type Config struct {
-	Token    string `mapstructure:"TOKEN"`
-	Port     string `mapstructure:"PORT"`
-	ChatID   int64  `mapstructure:"CHAT_ID"`
-	ThreadID int64  `mapstructure:"THREAD_ID"`
-	Links    Links  `mapstructure:"links"`
+	Token            string           `mapstructure:"TOKEN"`
+	BotUsername      string           `mapstructure:"BOT_USERNAME"`
+	Port             string           `mapstructure:"PORT"`
+	ApiUrl           string           `mapstructure:"API_URL"`
+	StorePath        string           `mapstructure:"STORE_PATH"`
+	ChatID           int64            `mapstructure:"CHAT_ID"`
+	ThreadID         int64            `mapstructure:"THREAD_ID"`
+	AdminChatID      int64            `mapstructure:"ADMIN_CHAT_ID"`
+	MetricsToken     string           `mapstructure:"METRICS_TOKEN"`
+	WebhookSecret    string           `mapstructure:"WEBHOOK_SECRET"`
+	Links            Links            `mapstructure:"links"`
+	Welcome          Welcome          `mapstructure:"welcome"`
+	Tracking         Tracking         `mapstructure:"tracking"`
+	LinkCheck        LinkCheck        `mapstructure:"link_check"`
+	Telegraph        Telegraph        `mapstructure:"telegraph"`
+	Products         []Product        `mapstructure:"products"`
+	Orders           Orders           `mapstructure:"orders"`
+	Faq              Faq              `mapstructure:"faq"`
+	Schedule         []ScheduledPost  `mapstructure:"schedule"`
+	Announcements    Announcements    `mapstructure:"announcements"`
+	Subscriptions    Subscriptions    `mapstructure:"subscriptions"`
+	Onboarding       Onboarding       `mapstructure:"onboarding"`
+	Campaigns        []Campaign       `mapstructure:"campaigns"`
+	JoinRequests     JoinRequests     `mapstructure:"join_requests"`
+	Bots             Bots             `mapstructure:"bots"`
+	ReturningMembers ReturningMembers `mapstructure:"returning_members"`
 }
 
-func newConfig() *Config {
+const defaultConfigFile = "config.toml"
+
+func loadConfig(configFile string) (*Config, error) {
 	err := godotenv.Load()
 	if err != nil {
-		log.Printf("Warning: Could not load .env file %v", err)
+		slog.Warn("Could not load .env file", "error", err)
 	}
 
 	v := viper.New()
 
-	v.SetConfigName("config")
+	v.SetConfigFile(configFile)
 	v.SetConfigType("toml")
-	v.AddConfigPath(".")
 	v.AutomaticEnv()
 
 	v.BindEnv("TOKEN")
+	v.BindEnv("BOT_USERNAME")
 	v.BindEnv("CHAT_ID")
 	v.BindEnv("THREAD_ID")
 	v.BindEnv("PORT")
+	v.BindEnv("API_URL")
+	v.BindEnv("ADMIN_CHAT_ID")
+	v.BindEnv("STORE_PATH")
+	v.BindEnv("METRICS_TOKEN")
+	v.BindEnv("WEBHOOK_SECRET")
+	v.BindEnv("telegraph.access_token", "TELEGRAPH_TOKEN")
 
-	if err := v.ReadInConfig(); err != nil {
-		log.Fatalf("Error reading config file: %s", err)
-	}
+	v.SetDefault("PORT", "4211")
+	v.SetDefault("API_URL", defaultTelegramApiUrl)
+	v.SetDefault("join_requests.challenge", challengeCaptcha)
+	v.SetDefault("join_requests.review", reviewAuto)
+	v.SetDefault("STORE_PATH", "store.json")
+	v.SetDefault("returning_members.mode", returningModeShort)
+	v.SetDefault("link_check.interval", "1h")
+	v.SetDefault("link_check.timeout", "10s")
+	v.SetDefault("link_check.failures", 3)
+	v.SetDefault("telegraph.api_url", defaultTelegraphApiUrl)
+	v.SetDefault("telegraph.content_dir", "content")
+	v.SetDefault("orders.delivery", []string{"Самовывоз", "Почта России", "СДЭК"})
+	v.SetDefault("orders.timeout", "30m")
+	v.SetDefault("faq.cooldown", "1h")
+	v.SetDefault("announcements.timezone", "Europe/Moscow")
+	v.SetDefault("subscriptions.rate", 20)
 
-	botToken := v.GetString("TOKEN")
-	if botToken == "" {
-		log.Fatal("TOKEN environment variable not set")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
 	config := &Config{}
 	if err := v.Unmarshal(config); err != nil {
-		log.Fatalf("Error unmarshaling config: %s", err)
+		return nil, fmt.Errorf("unmarshaling config: %w", err)
 	}
 
-	log.Printf("Config: %+v", v.AllKeys())
-	if config.Token == "" {
-		log.Fatal("TOKEN environment variable not set")
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func validateUrl(name string, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%s must be an http(s) URL, got %q", name, value)
+	}
+	return nil
+}
+
+func validateButtons(name string, buttons []Button) []error {
+	var errs []error
+	for i, button := range buttons {
+		if button.Text == "" {
+			errs = append(errs, fmt.Errorf("%s[%d].text is not set", name, i))
+		}
+		if err := validateUrl(fmt.Sprintf("%s[%d].url", name, i), button.Url); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func validatePort(port string) error {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("PORT must be a number between 1 and 65535, got %q", port)
+	}
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	return slices.Contains(allowed, value)
+}
+
+// validate reports every problem at once, so a broken deploy can be fixed in one go.
+// Errors never include the token value.
+func (c *Config) validate() error {
+	var errs []error
+
+	if c.Token == "" {
+		errs = append(errs, errors.New("TOKEN is not set"))
+	}
+	if err := validatePort(c.Port); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateUrl("API_URL", c.ApiUrl); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ChatID == 0 {
+		errs = append(errs, errors.New("CHAT_ID is not set"))
+	}
+	if c.ThreadID < 0 {
+		errs = append(errs, fmt.Errorf("THREAD_ID must not be negative, got %d", c.ThreadID))
+	}
+	if c.WebhookSecret != "" && !webhookSecretPattern.MatchString(c.WebhookSecret) {
+		errs = append(errs, errors.New("WEBHOOK_SECRET must be 1-256 characters A-Z, a-z, 0-9, _ or -"))
+	}
+
+	links := []struct {
+		name  string
+		value string
+	}{
+		{"links.distillate", c.Links.Distillate},
+		{"links.prices", c.Links.Prices},
+		{"links.soap", c.Links.Soap},
+		{"links.ubtan", c.Links.Ubtan},
+	}
+	for _, link := range links {
+		if err := validateUrl(link.name, link.value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(c.Welcome.Media) > maxMediaGroupSize {
+		errs = append(errs, fmt.Errorf("welcome.media must have at most %d items, got %d", maxMediaGroupSize, len(c.Welcome.Media)))
+	}
+	for i, media := range c.Welcome.Media {
+		if (media.File == "") == (media.FileID == "") {
+			errs = append(errs, fmt.Errorf("welcome.media[%d] must set either file or file_id", i))
+			continue
+		}
+		if media.File != "" {
+			if _, err := os.Stat(media.File); err != nil {
+				errs = append(errs, fmt.Errorf("welcome.media[%d]: %w", i, err))
+			}
+		}
+	}
+
+	if c.Welcome.Private && c.BotUsername == "" {
+		errs = append(errs, errors.New("welcome.private requires BOT_USERNAME for the link to the bot"))
+	}
+
+	for i, topic := range c.Welcome.Topics {
+		if topic.ID < generalTopicID {
+			errs = append(errs, fmt.Errorf("welcome.topics[%d].id must be a forum topic id, got %d", i, topic.ID))
+		}
+		if i > 0 && topic.Template == "" {
+			errs = append(errs, fmt.Errorf("welcome.topics[%d].template is not set, only the first topic gets the full welcome", i))
+		}
+		errs = append(errs, validateButtons(fmt.Sprintf("welcome.topics[%d].buttons", i), topic.Buttons)...)
+	}
+
+	if c.Tracking.Enabled {
+		if err := validateUrl("tracking.base_url", c.Tracking.BaseUrl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The redirect endpoint finds the link by the button id, so an id must always lead to the same link
+	buttonUrls := make(map[string]string)
+	for _, button := range c.buttons(&c.Links) {
+		if button.ID == "" {
+			continue
+		}
+		if other, ok := buttonUrls[button.ID]; ok && other != button.Url {
+			errs = append(errs, fmt.Errorf("button id %q is used for different links: %s and %s", button.ID, other, button.Url))
+		}
+		buttonUrls[button.ID] = button.Url
+	}
+
+	if c.LinkCheck.Enabled {
+		if c.LinkCheck.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("link_check.interval must be positive, got %s", c.LinkCheck.Interval))
+		}
+		if c.LinkCheck.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("link_check.timeout must be positive, got %s", c.LinkCheck.Timeout))
+		}
+		if c.LinkCheck.Failures < 1 {
+			errs = append(errs, fmt.Errorf("link_check.failures must be at least 1, got %d", c.LinkCheck.Failures))
+		}
+	}
+
+	productIDs := make(map[string]bool)
+	for i, product := range c.Products {
+		switch {
+		case product.ID == "":
+			errs = append(errs, fmt.Errorf("products[%d].id is not set", i))
+		case !productIDPattern.MatchString(product.ID):
+			errs = append(errs, fmt.Errorf("products[%d].id must be up to 32 latin letters, digits, \"_\" or \"-\", got %q", i, product.ID))
+		case productIDs[product.ID]:
+			errs = append(errs, fmt.Errorf("products[%d].id %q is used more than once", i, product.ID))
+		}
+		productIDs[product.ID] = true
+		if product.Name == "" {
+			errs = append(errs, fmt.Errorf("products[%d].name is not set", i))
+		}
+		if product.Category == "" {
+			errs = append(errs, fmt.Errorf("products[%d].category is not set", i))
+		}
+		if product.Price < 0 {
+			errs = append(errs, fmt.Errorf("products[%d].price must not be negative, got %d", i, product.Price))
+		}
+		if product.Photo != "" {
+			if err := validateUrl(fmt.Sprintf("products[%d].photo", i), product.Photo); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if c.Orders.Enabled {
+		if len(c.Products) == 0 {
+			errs = append(errs, errors.New("orders need at least one entry in [[products]]"))
+		}
+		if len(c.Orders.Delivery) == 0 {
+			errs = append(errs, errors.New("orders.delivery must list at least one delivery method"))
+		}
+		if c.Orders.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("orders.timeout must be positive, got %s", c.Orders.Timeout))
+		}
+		if c.AdminChatID == 0 {
+			errs = append(errs, errors.New("ADMIN_CHAT_ID is required for orders"))
+		}
+	}
+
+	if c.Faq.Enabled {
+		if c.Faq.Cooldown < 0 {
+			errs = append(errs, fmt.Errorf("faq.cooldown must not be negative, got %s", c.Faq.Cooldown))
+		}
+		for i, entry := range c.Faq.Entries {
+			if len(entry.Triggers) == 0 && len(entry.Patterns) == 0 {
+				errs = append(errs, fmt.Errorf("faq.entries[%d] must set triggers or patterns", i))
+			}
+			for _, pattern := range entry.Patterns {
+				if _, err := compileFaqPattern(pattern); err != nil {
+					errs = append(errs, fmt.Errorf("faq.entries[%d] pattern %q: %w", i, pattern, err))
+				}
+			}
+			if entry.Answer == "" {
+				errs = append(errs, fmt.Errorf("faq.entries[%d].answer is not set", i))
+			}
+			errs = append(errs, validateButtons(fmt.Sprintf("faq.entries[%d].buttons", i), entry.Buttons)...)
+		}
+	}
+
+	scheduleIDs := make(map[string]bool)
+	for i, post := range c.Schedule {
+		switch {
+		case post.ID == "":
+			errs = append(errs, fmt.Errorf("schedule[%d].id is not set", i))
+		case scheduleIDs[post.ID]:
+			errs = append(errs, fmt.Errorf("schedule[%d].id %q is used more than once", i, post.ID))
+		}
+		scheduleIDs[post.ID] = true
+		if _, err := parseCron(post.Cron); err != nil {
+			errs = append(errs, fmt.Errorf("schedule[%d].cron: %w", i, err))
+		}
+		if _, err := time.LoadLocation(post.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("schedule[%d].timezone: %w", i, err))
+		}
+		if post.ThreadID < 0 {
+			errs = append(errs, fmt.Errorf("schedule[%d].thread_id must not be negative, got %d", i, post.ThreadID))
+		}
+		if post.Template == "" {
+			errs = append(errs, fmt.Errorf("schedule[%d].template is not set", i))
+		}
+		errs = append(errs, validateButtons(fmt.Sprintf("schedule[%d].buttons", i), post.Buttons)...)
+	}
+
+	if _, err := time.LoadLocation(c.Announcements.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("announcements.timezone: %w", err))
+	}
+
+	if c.Subscriptions.Rate < 1 || c.Subscriptions.Rate > maxBroadcastRate {
+		errs = append(errs, fmt.Errorf("subscriptions.rate must be between 1 and %d, got %d", maxBroadcastRate, c.Subscriptions.Rate))
+	}
+
+	if c.Onboarding.Enabled {
+		if len(c.Onboarding.Steps) == 0 {
+			errs = append(errs, errors.New("onboarding.steps must not be empty"))
+		}
+		for i, step := range c.Onboarding.Steps {
+			if step.Delay < 0 {
+				errs = append(errs, fmt.Errorf("onboarding.steps[%d].delay must not be negative, got %s", i, step.Delay))
+			}
+			if i > 0 && step.Delay < c.Onboarding.Steps[i-1].Delay {
+				errs = append(errs, fmt.Errorf("onboarding.steps[%d].delay must not be shorter than the previous step", i))
+			}
+			if step.Text == "" {
+				errs = append(errs, fmt.Errorf("onboarding.steps[%d].text is not set", i))
+			}
+			errs = append(errs, validateButtons(fmt.Sprintf("onboarding.steps[%d].buttons", i), step.Buttons)...)
+		}
+	}
+
+	campaignIDs := make(map[string]bool)
+	for i, campaign := range c.Campaigns {
+		switch {
+		case !startPayloadPattern.MatchString(campaign.ID):
+			errs = append(errs, fmt.Errorf("campaigns[%d].id must be up to 64 latin letters, digits, \"_\" or \"-\", got %q", i, campaign.ID))
+		case isReservedStartPayload(campaign.ID):
+			errs = append(errs, fmt.Errorf("campaigns[%d].id %q is used by the bot", i, campaign.ID))
+		case campaignIDs[campaign.ID]:
+			errs = append(errs, fmt.Errorf("campaigns[%d].id %q is used more than once", i, campaign.ID))
+		}
+		campaignIDs[campaign.ID] = true
+		errs = append(errs, validateButtons(fmt.Sprintf("campaigns[%d].buttons", i), campaign.Buttons)...)
+	}
+
+	if c.Telegraph.AccessToken != "" {
+		if err := validateUrl("telegraph.api_url", c.Telegraph.ApiUrl); err != nil {
+			errs = append(errs, err)
+		}
+		if c.Telegraph.ContentDir == "" {
+			errs = append(errs, errors.New("telegraph.content_dir is not set"))
+		}
+	}
+
+	if c.JoinRequests.Enabled {
+		if !oneOf(c.JoinRequests.Challenge, challengeCaptcha, challengeQuestion) {
+			errs = append(errs, fmt.Errorf("join_requests.challenge must be %q or %q, got %q", challengeCaptcha, challengeQuestion, c.JoinRequests.Challenge))
+		}
+		if c.JoinRequests.Challenge == challengeQuestion && c.JoinRequests.Question == "" {
+			errs = append(errs, errors.New("join_requests.question is required for the question challenge"))
+		}
+		if !oneOf(c.JoinRequests.Review, reviewAuto, reviewAdmin) {
+			errs = append(errs, fmt.Errorf("join_requests.review must be %q or %q, got %q", reviewAuto, reviewAdmin, c.JoinRequests.Review))
+		}
+		if c.JoinRequests.Review == reviewAdmin && c.AdminChatID == 0 {
+			errs = append(errs, errors.New("ADMIN_CHAT_ID is required for admin review of join requests"))
+		}
+	}
+
+	if c.ReturningMembers.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("returning_members.cooldown must not be negative, got %s", c.ReturningMembers.Cooldown))
+	}
+	if !oneOf(c.ReturningMembers.Mode, returningModeShort, returningModeSkip) {
+		errs = append(errs, fmt.Errorf("returning_members.mode must be %q or %q, got %q", returningModeShort, returningModeSkip, c.ReturningMembers.Mode))
 	}
 
-	return config
+	return errors.Join(errs...)
 }
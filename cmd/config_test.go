@@ -1,7 +1,11 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfigStruct(t *testing.T) {
@@ -59,3 +63,297 @@ func TestLinksStruct(t *testing.T) {
 		t.Error("Ubtan link should not be empty")
 	}
 }
+
+func createValidTestConfig() *Config {
+	return &Config{
+		Token:    "123456789:secret-token",
+		Port:     "8080",
+		ApiUrl:   defaultTelegramApiUrl,
+		ChatID:   123456789,
+		ThreadID: 2,
+		Links: Links{
+			Distillate: "https://example.com/distillate",
+			Prices:     "https://example.com/prices",
+			Soap:       "https://example.com/soap",
+			Ubtan:      "https://example.com/ubtan",
+		},
+		JoinRequests: JoinRequests{
+			Challenge: challengeCaptcha,
+			Review:    reviewAuto,
+		},
+		ReturningMembers: ReturningMembers{
+			Mode: returningModeShort,
+		},
+		Subscriptions: Subscriptions{
+			Rate: 20,
+		},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(config *Config)
+		expectedErrors []string
+	}{
+		{
+			name:   "valid config",
+			modify: func(config *Config) {},
+		},
+		{
+			name: "missing token and chat id",
+			modify: func(config *Config) {
+				config.Token = ""
+				config.ChatID = 0
+			},
+			expectedErrors: []string{"TOKEN is not set", "CHAT_ID is not set"},
+		},
+		{
+			name: "bad port and negative thread id",
+			modify: func(config *Config) {
+				config.Port = "http"
+				config.ThreadID = -1
+			},
+			expectedErrors: []string{"PORT must be a number", "THREAD_ID must not be negative"},
+		},
+		{
+			name: "webhook secret with forbidden characters",
+			modify: func(config *Config) {
+				config.WebhookSecret = "not a secret!"
+			},
+			expectedErrors: []string{"WEBHOOK_SECRET must be"},
+		},
+		{
+			name: "malformed links",
+			modify: func(config *Config) {
+				config.Links.Soap = "telegra.ph/soap"
+				config.Links.Ubtan = "ftp://example.com/ubtan"
+			},
+			expectedErrors: []string{"links.soap", "links.ubtan"},
+		},
+		{
+			name: "admin review without admin chat",
+			modify: func(config *Config) {
+				config.JoinRequests.Enabled = true
+				config.JoinRequests.Review = reviewAdmin
+			},
+			expectedErrors: []string{"ADMIN_CHAT_ID is required"},
+		},
+		{
+			name: "welcome media without source",
+			modify: func(config *Config) {
+				config.Welcome.Media = []WelcomeMedia{{}, {File: "missing.jpg", FileID: "AgAC"}}
+			},
+			expectedErrors: []string{"welcome.media[0] must set", "welcome.media[1] must set"},
+		},
+		{
+			name: "welcome media file does not exist",
+			modify: func(config *Config) {
+				config.Welcome.Media = []WelcomeMedia{{File: "missing.jpg"}}
+			},
+			expectedErrors: []string{"welcome.media[0]: stat missing.jpg"},
+		},
+		{
+			name: "welcome topic with bad id and button",
+			modify: func(config *Config) {
+				config.Welcome.Topics = []WelcomeTopic{{ID: 0, Buttons: []Button{{Url: "example.com"}}}}
+			},
+			expectedErrors: []string{"welcome.topics[0].id", "buttons[0].text", "buttons[0].url"},
+		},
+		{
+			name: "button id used for different links",
+			modify: func(config *Config) {
+				config.Welcome.Topics = []WelcomeTopic{{ID: 5, Buttons: []Button{{ID: "soap", Text: "Мыло", Url: "https://example.com/other"}}}}
+			},
+			expectedErrors: []string{`button id "soap" is used for different links`},
+		},
+		{
+			name: "second welcome topic without template",
+			modify: func(config *Config) {
+				config.Welcome.Topics = []WelcomeTopic{{ID: 5}, {ID: 6}}
+			},
+			expectedErrors: []string{"welcome.topics[1].template is not set"},
+		},
+		{
+			name: "private welcome without bot username",
+			modify: func(config *Config) {
+				config.Welcome.Private = true
+				config.BotUsername = ""
+			},
+			expectedErrors: []string{"welcome.private requires BOT_USERNAME"},
+		},
+		{
+			name: "invalid products",
+			modify: func(config *Config) {
+				config.Products = []Product{
+					{ID: "soap", Name: "Мыло", Category: "Мыло", Price: 450},
+					{ID: "soap", Category: "Мыло", Price: -1, Photo: "soap.jpg"},
+					{ID: "a:b", Name: "Убтан"},
+				}
+			},
+			expectedErrors: []string{
+				"products[1].id \"soap\" is used more than once",
+				"products[1].name", "products[1].price", "products[1].photo",
+				"products[2].id must be", "products[2].category",
+			},
+		},
+		{
+			name: "orders without products, delivery and admin chat",
+			modify: func(config *Config) {
+				config.Orders = Orders{Enabled: true}
+			},
+			expectedErrors: []string{"[[products]]", "orders.delivery", "orders.timeout", "ADMIN_CHAT_ID is required for orders"},
+		},
+		{
+			name: "invalid faq entries",
+			modify: func(config *Config) {
+				config.Faq = Faq{Enabled: true, Entries: []FaqEntry{
+					{Answer: "Ответ"},
+					{Patterns: []string{"доставк(а"}},
+				}}
+			},
+			expectedErrors: []string{"faq.entries[0] must set triggers", "faq.entries[1] pattern", "faq.entries[1].answer"},
+		},
+		{
+			name: "invalid schedule",
+			modify: func(config *Config) {
+				config.Schedule = []ScheduledPost{
+					{ID: "news", Cron: "0 10 * * 8", Timezone: "Europe/Moscow", Template: "Новинки"},
+					{ID: "news", Cron: "0 10 * * 1", Timezone: "Mars/Olympus"},
+				}
+			},
+			expectedErrors: []string{"schedule[0].cron", "schedule[1].id \"news\" is used more than once", "schedule[1].timezone", "schedule[1].template"},
+		},
+		{
+			name: "broadcast rate over the Telegram limit",
+			modify: func(config *Config) {
+				config.Subscriptions.Rate = 100
+			},
+			expectedErrors: []string{"subscriptions.rate must be between 1 and 30"},
+		},
+		{
+			name: "invalid campaigns",
+			modify: func(config *Config) {
+				config.Campaigns = []Campaign{{ID: "ярмарка"}, {ID: "subscribe"}, {ID: "fair"}, {ID: "fair"}}
+			},
+			expectedErrors: []string{
+				"campaigns[0].id must be up to 64",
+				"campaigns[1].id \"subscribe\" is used by the bot",
+				"campaigns[3].id \"fair\" is used more than once",
+			},
+		},
+		{
+			name: "invalid onboarding steps",
+			modify: func(config *Config) {
+				config.Onboarding = Onboarding{Enabled: true, Steps: []OnboardingStep{
+					{Delay: 48 * time.Hour, Text: "Как выбрать мыло"},
+					{Delay: time.Hour, Buttons: []Button{{Text: "Каталог", Url: "example.com"}}},
+				}}
+			},
+			expectedErrors: []string{
+				"onboarding.steps[1].delay must not be shorter",
+				"onboarding.steps[1].text is not set",
+				"onboarding.steps[1].buttons[0].url",
+			},
+		},
+		{
+			name: "unknown returning members mode",
+			modify: func(config *Config) {
+				config.ReturningMembers.Mode = "loud"
+			},
+			expectedErrors: []string{"returning_members.mode"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createValidTestConfig()
+			tt.modify(config)
+
+			err := config.validate()
+			if len(tt.expectedErrors) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			for _, expected := range tt.expectedErrors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error to contain %q, got %v", expected, err)
+				}
+			}
+			if strings.Contains(err.Error(), "secret-token") {
+				t.Error("Expected error to not contain the token")
+			}
+		})
+	}
+}
+
+func writeTestConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TOKEN", "123456789:secret-token")
+	t.Setenv("CHAT_ID", "123456789")
+	t.Setenv("PORT", "8080")
+
+	path := writeTestConfigFile(t, `
+[links]
+distillate = "https://example.com/distillate"
+prices = "https://example.com/prices"
+soap = "https://example.com/soap"
+ubtan = "https://example.com/ubtan"
+
+[returning_members]
+cooldown = "24h"
+`)
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.ChatID != 123456789 {
+		t.Errorf("Expected ChatID 123456789, got %d", config.ChatID)
+	}
+	if config.ReturningMembers.Cooldown != 24*time.Hour {
+		t.Errorf("Expected cooldown 24h, got %s", config.ReturningMembers.Cooldown)
+	}
+	if config.ReturningMembers.Mode != returningModeShort {
+		t.Errorf("Expected default mode %s, got %s", returningModeShort, config.ReturningMembers.Mode)
+	}
+}
+
+func TestLoadConfigAggregatesErrors(t *testing.T) {
+	t.Setenv("TOKEN", "")
+	t.Setenv("CHAT_ID", "0")
+
+	path := writeTestConfigFile(t, `
+[links]
+prices = "not a url"
+`)
+
+	_, err := loadConfig(path)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	for _, expected := range []string{"TOKEN is not set", "CHAT_ID is not set", "links.prices", "links.soap"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	_, err := loadConfig(filepath.Join(t.TempDir(), "missing.toml"))
+	if err == nil {
+		t.Error("Expected error for missing config file")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := parseCron(expression); err == nil {
+				t.Errorf("Expected %q to be rejected", expression)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wednesday
+	from := time.Date(2026, 3, 4, 10, 30, 15, 0, moscow)
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{expression: "* * * * *", expected: time.Date(2026, 3, 4, 10, 31, 0, 0, moscow)},
+		{expression: "*/15 * * * *", expected: time.Date(2026, 3, 4, 10, 45, 0, 0, moscow)},
+		{expression: "0 10 * * *", expected: time.Date(2026, 3, 5, 10, 0, 0, 0, moscow)},
+		{expression: "0 10 * * 1", expected: time.Date(2026, 3, 9, 10, 0, 0, 0, moscow)},
+		{expression: "0 18 * * 5,7", expected: time.Date(2026, 3, 6, 18, 0, 0, 0, moscow)},
+		{expression: "0 9 * * 0", expected: time.Date(2026, 3, 8, 9, 0, 0, 0, moscow)},
+		{expression: "0 12 1 * *", expected: time.Date(2026, 4, 1, 12, 0, 0, 0, moscow)},
+		{expression: "0 12 1 * 3", expected: time.Date(2026, 3, 4, 12, 0, 0, 0, moscow)},
+		{expression: "0 0 29 2 *", expected: time.Date(2028, 2, 29, 0, 0, 0, 0, moscow)},
+		{expression: "0 0 30 2 *", expected: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			schedule, err := parseCron(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := schedule.next(from); !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
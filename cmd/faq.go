@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faqRule is a compiled FAQ entry: trigger phrases as word stems and regular expressions
+type faqRule struct {
+	triggers [][]string
+	patterns []*regexp.Regexp
+}
+
+type FaqResponder struct {
+	rules []faqRule
+
+	mu        sync.Mutex
+	lastReply map[string]time.Time
+}
+
+// normalizeFaqText lowercases text and replaces ё, so patterns don't have to care about either
+func normalizeFaqText(text string) string {
+	return strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+}
+
+func stemWords(text string) []string {
+	words := searchTokens(text)
+	for i, word := range words {
+		words[i] = stemRussian(word)
+	}
+	return words
+}
+
+// compileFaqPattern folds ё in the pattern like in the text. The pattern is not lowercased,
+// that would turn escapes like \S into their opposites, (?i) takes care of the case.
+func compileFaqPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + strings.NewReplacer("ё", "е", "Ё", "е").Replace(pattern))
+}
+
+func newFaqResponder(entries []FaqEntry) *FaqResponder {
+	responder := &FaqResponder{lastReply: make(map[string]time.Time)}
+	for i, entry := range entries {
+		var rule faqRule
+		for _, trigger := range entry.Triggers {
+			if stems := stemWords(trigger); len(stems) > 0 {
+				rule.triggers = append(rule.triggers, stems)
+			}
+		}
+		for _, pattern := range entry.Patterns {
+			compiled, err := compileFaqPattern(pattern)
+			if err != nil {
+				// Config validation rejects these, a broken pattern only disables itself
+				slog.Error("Invalid FAQ pattern", "entry", i, "pattern", pattern, "error", err)
+				continue
+			}
+			rule.patterns = append(rule.patterns, compiled)
+		}
+		responder.rules = append(responder.rules, rule)
+	}
+	return responder
+}
+
+// containsInOrder reports whether every stem of phrase occurs in words, in the same order
+// but not necessarily next to each other
+func containsInOrder(words []string, phrase []string) bool {
+	next := 0
+	for _, word := range words {
+		if next < len(phrase) && word == phrase[next] {
+			next++
+		}
+	}
+	return next == len(phrase)
+}
+
+func (r *faqRule) matches(text string, words []string) bool {
+	for _, trigger := range r.triggers {
+		if containsInOrder(words, trigger) {
+			return true
+		}
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the index of the first FAQ entry matching text
+func (f *FaqResponder) match(text string) (int, bool) {
+	normalized := normalizeFaqText(text)
+	words := stemWords(normalized)
+	for i := range f.rules {
+		if f.rules[i].matches(normalized, words) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// allow reports whether entry may be answered in chatID again and starts its cooldown if so
+func (f *FaqResponder) allow(chatID int64, entry int, cooldown time.Duration, now time.Time) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := fmt.Sprintf("%d:%d", chatID, entry)
+	if last, ok := f.lastReply[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	f.lastReply[key] = now
+	return true
+}
+
+func (app *App) isFaqQuestion(message *Message) bool {
+	return app.config.Faq.Enabled && message != nil && message.Text != "" && !message.From.IsBot &&
+		message.Chat.ID == app.config.ChatID && !isCommand(message)
+}
+
+func (app *App) handleFaqQuestion(message *Message) {
+	entry, ok := app.faq.match(message.Text)
+	if !ok || !app.faq.allow(message.Chat.ID, entry, app.config.Faq.Cooldown, time.Now()) {
+		return
+	}
+
+	settings := &app.config.Faq.Entries[entry]
+	links := app.links()
+	params := map[string]any{
+		"text": renderLinks(settings.Answer, &links),
+		"reply_parameters": map[string]any{
+			"message_id":                  message.MessageID,
+			"allow_sending_without_reply": true,
+		},
+	}
+	if len(settings.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(app.trackButtons(settings.Buttons, message.Chat.ID))
+	}
+	slog.Info("Answering FAQ", "chat_id", message.Chat.ID, "entry", entry)
+	app.reply(message, params)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var testFaqEntries = []FaqEntry{
+	{Triggers: []string{"сколько стоит", "цена"}, Answer: "Цены: {prices}"},
+	{Triggers: []string{"доставка"}, Patterns: []string{`отправ(ляете|ите).*сдэк`}, Answer: "Отправляем СДЭК"},
+}
+
+func faqTestConfig(config *Config) {
+	config.Faq = Faq{Enabled: true, Cooldown: time.Hour, Entries: testFaqEntries}
+}
+
+func createTestGroupMessage(messageID int64, text string) *Update {
+	update := createTestCommand(text)
+	update.Message.MessageID = messageID
+	return update
+}
+
+func TestFaqMatch(t *testing.T) {
+	faq := newFaqResponder(testFaqEntries)
+
+	tests := []struct {
+		text          string
+		expectedEntry int
+		expectedOk    bool
+	}{
+		{text: "Сколько стоит лавандовое мыло?", expectedEntry: 0, expectedOk: true},
+		{text: "А сколько у вас всё это стоит", expectedEntry: 0, expectedOk: true},
+		{text: "Какие цены?", expectedEntry: 0, expectedOk: true},
+		{text: "Есть доставка в Казань?", expectedEntry: 1, expectedOk: true},
+		{text: "Про доставку можно узнать?", expectedEntry: 1, expectedOk: true},
+		{text: "Отправите через СДЭК?", expectedEntry: 1, expectedOk: true},
+		{text: "Стоит ли брать сколько-нибудь", expectedOk: false},
+		{text: "Спасибо, мыло чудесное!", expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			entry, ok := faq.match(tt.text)
+			if entry != tt.expectedEntry || ok != tt.expectedOk {
+				t.Errorf("Expected %d %v, got %d %v", tt.expectedEntry, tt.expectedOk, entry, ok)
+			}
+		})
+	}
+}
+
+func TestCompileFaqPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		text     string
+		expected bool
+	}{
+		{pattern: `цена\S+`, text: "Ценами довольны", expected: true},
+		{pattern: `цена\S+`, text: "цена 100", expected: false},
+		{pattern: `заказ №\D`, text: "Заказ №А", expected: true},
+		{pattern: `заказ №\D`, text: "заказ №5", expected: false},
+		{pattern: `ёлочн`, text: "Елочное мыло", expected: true},
+		{pattern: `елочн`, text: "Ёлочное мыло", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.text, func(t *testing.T) {
+			compiled, err := compileFaqPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result := compiled.MatchString(normalizeFaqText(tt.text)); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFaqCooldown(t *testing.T) {
+	faq := newFaqResponder(testFaqEntries)
+	now := time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		chatID   int64
+		entry    int
+		at       time.Time
+		expected bool
+	}{
+		{name: "first question", chatID: 1, entry: 0, at: now, expected: true},
+		{name: "repeated question", chatID: 1, entry: 0, at: now.Add(30 * time.Minute), expected: false},
+		{name: "other question", chatID: 1, entry: 1, at: now.Add(30 * time.Minute), expected: true},
+		{name: "other chat", chatID: 2, entry: 0, at: now.Add(30 * time.Minute), expected: true},
+		{name: "after cooldown", chatID: 1, entry: 0, at: now.Add(time.Hour), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := faq.allow(tt.chatID, tt.entry, time.Hour, tt.at); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFaqQuestionInGroup(t *testing.T) {
+	app, api := newTestApp(t, faqTestConfig)
+
+	app.handleTelegramUpdate(createTestGroupMessage(42, "Подскажите, сколько стоит мыло?"))
+	app.handleTelegramUpdate(createTestGroupMessage(43, "Сколько стоит шампунь?"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 answer within the cooldown, got %d", len(calls))
+	}
+	if calls[0].Params["text"] != "Цены: https://example.com/prices" {
+		t.Errorf("Expected the answer with links, got %v", calls[0].Params["text"])
+	}
+	reply := calls[0].Params["reply_parameters"].(map[string]any)
+	if reply["message_id"] != float64(42) {
+		t.Errorf("Expected a reply to the question, got %v", reply)
+	}
+}
+
+func TestFaqIgnoresOtherChatsAndCommands(t *testing.T) {
+	app, api := newTestApp(t, faqTestConfig)
+	otherGroup := createTestGroupMessage(44, "Сколько стоит мыло?")
+	otherGroup.Message.Chat.ID = 987654321
+
+	app.handleTelegramUpdate(createTestPrivateMessage("Сколько стоит мыло?"))
+	app.handleTelegramUpdate(createTestGroupMessage(42, "/unknown цена"))
+	app.handleTelegramUpdate(otherGroup)
+
+	if calls := api.callsTo("sendMessage"); len(calls) != 0 {
+		t.Errorf("Expected no answers, got %v", calls)
+	}
+}
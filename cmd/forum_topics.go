@@ -0,0 +1,43 @@
+package main
+
+import "strings"
+
+// generalTopicID is the forum's General topic, messages to it are sent without message_thread_id
+const generalTopicID = 1
+
+func setMessageThreadID(params map[string]any, threadID int64) {
+	if threadID > generalTopicID {
+		params["message_thread_id"] = threadID
+	}
+}
+
+func renderMentionsTemplate(template string, members []User) string {
+	return strings.ReplaceAll(template, "{mentions}", joinUserMentions(members))
+}
+
+// defaultWelcomeTopic posts the standard welcome to THREAD_ID, or to the General topic when it's not set
+func (app *App) defaultWelcomeTopic() WelcomeTopic {
+	return WelcomeTopic{ID: app.config.ThreadID}
+}
+
+func (app *App) welcomeTopics() []WelcomeTopic {
+	if len(app.config.Welcome.Topics) == 0 {
+		return []WelcomeTopic{app.defaultWelcomeTopic()}
+	}
+	return app.config.Welcome.Topics
+}
+
+// topicNoteParams is the short message with the topic's own template and buttons
+// that the welcome topics after the first one get
+func (app *App) topicNoteParams(topic WelcomeTopic, members []User) map[string]any {
+	params := map[string]any{
+		"chat_id":    app.config.ChatID,
+		"text":       renderMentionsTemplate(topic.Template, members),
+		"parse_mode": "HTML",
+	}
+	if len(topic.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(app.trackButtons(topic.Buttons, app.config.ChatID))
+	}
+	setMessageThreadID(params, topic.ID)
+	return params
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetMessageThreadID(t *testing.T) {
+	tests := []struct {
+		name     string
+		threadID int64
+		expected any
+	}{
+		{name: "no topic", threadID: 0, expected: nil},
+		{name: "general topic", threadID: generalTopicID, expected: nil},
+		{name: "forum topic", threadID: 5, expected: int64(5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]any{}
+			setMessageThreadID(params, tt.threadID)
+			if params["message_thread_id"] != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, params["message_thread_id"])
+			}
+		})
+	}
+}
+
+func TestWelcomeTopics(t *testing.T) {
+	app := &App{config: &Config{ThreadID: 3}}
+
+	topics := app.welcomeTopics()
+	if len(topics) != 1 || topics[0].ID != 3 {
+		t.Errorf("Expected default topic 3, got %+v", topics)
+	}
+
+	app.config.Welcome.Topics = []WelcomeTopic{{ID: 5}, {ID: 6}}
+	topics = app.welcomeTopics()
+	if len(topics) != 2 || topics[0].ID != 5 {
+		t.Errorf("Expected configured topics, got %+v", topics)
+	}
+}
+
+func TestWelcomePerTopic(t *testing.T) {
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{FileID: "configured"}))
+	app.config.Welcome.Topics = []WelcomeTopic{
+		{ID: 5, Name: "Знакомство"},
+		{
+			ID:       6,
+			Name:     "Заказы",
+			Template: "{mentions}, заказы принимаем здесь",
+			Buttons:  []Button{{Text: "Прайс", Url: "https://example.com/prices"}},
+		},
+	}
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	photos := api.callsTo("sendPhoto")
+	if len(photos) != 1 || photos[0].Params["message_thread_id"] != float64(5) {
+		t.Fatalf("Expected the full welcome in topic 5 only, got %+v", photos)
+	}
+	if !strings.HasPrefix(photos[0].Params["caption"].(string), "Привет, @janesmith!") {
+		t.Errorf("Expected default welcome in topic 5, got %s", photos[0].Params["caption"])
+	}
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["message_thread_id"] != float64(6) {
+		t.Fatalf("Expected a note in topic 6, got %+v", calls)
+	}
+	if calls[0].Params["text"] != "@janesmith, заказы принимаем здесь" {
+		t.Errorf("Expected topic template in topic 6, got %s", calls[0].Params["text"])
+	}
+
+	keyboard := calls[0].Params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)
+	if len(keyboard) != 1 {
+		t.Errorf("Expected topic buttons in topic 6, got %v", keyboard)
+	}
+}
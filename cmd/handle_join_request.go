@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	challengeCaptcha  = "captcha"
+	challengeQuestion = "question"
+	reviewAuto        = "auto"
+	reviewAdmin       = "admin"
+
+	joinCallbackPrefix = "join"
+	joinActionApprove  = "approve"
+	joinActionDecline  = "decline"
+)
+
+// PendingJoinRequest is a join request waiting for the applicant's answer or the admins' decision
+type PendingJoinRequest struct {
+	ChatID         int64  `json:"chat_id"`
+	User           User   `json:"user"`
+	UserChatID     int64  `json:"user_chat_id"`
+	Question       string `json:"question"`
+	ExpectedAnswer string `json:"expected_answer,omitempty"`
+	Answered       bool   `json:"answered,omitempty"`
+}
+
+// joinRequest returns a copy of the pending join request of userID
+func (app *App) joinRequest(userID int64) (PendingJoinRequest, bool) {
+	var request PendingJoinRequest
+	var ok bool
+	app.store.view(func(data *storeData) {
+		var stored *PendingJoinRequest
+		stored, ok = data.JoinRequests[userID]
+		if ok {
+			request = *stored
+		}
+	})
+	return request, ok
+}
+
+func (app *App) saveJoinRequest(request PendingJoinRequest) {
+	err := app.store.update(func(data *storeData) {
+		data.JoinRequests[request.User.ID] = &request
+	})
+	if err != nil {
+		slog.Error("Error saving join request", "user_id", request.User.ID, "error", err)
+	}
+}
+
+func (app *App) deleteJoinRequest(userID int64) {
+	err := app.store.update(func(data *storeData) {
+		delete(data.JoinRequests, userID)
+	})
+	if err != nil {
+		slog.Error("Error deleting join request", "user_id", userID, "error", err)
+	}
+}
+
+func (app *App) isJoinRequestForGroup(request *ChatJoinRequest) bool {
+	return request != nil &&
+		app.config.JoinRequests.Enabled &&
+		request.Chat.ID == app.config.ChatID
+}
+
+func (app *App) isJoinRequestAnswer(message *Message) bool {
+	if message == nil || message.Chat.Type != "private" || message.Text == "" {
+		return false
+	}
+	request, ok := app.joinRequest(message.From.ID)
+	return ok && !request.Answered
+}
+
+func createCaptcha() (question string, answer string) {
+	a := rand.IntN(9) + 1
+	b := rand.IntN(9) + 1
+	return fmt.Sprintf("Сколько будет %d + %d? Ответьте числом.", a, b), strconv.Itoa(a + b)
+}
+
+func createJoinRequestQuestion(settings *JoinRequests) (question string, answer string) {
+	if settings.Challenge == challengeQuestion {
+		return settings.Question, ""
+	}
+	return createCaptcha()
+}
+
+func normalizeAnswer(answer string) string {
+	return strings.ToLower(strings.TrimSpace(answer))
+}
+
+func isAcceptedAnswer(request *PendingJoinRequest, settings *JoinRequests, answer string) bool {
+	answer = normalizeAnswer(answer)
+	if request.ExpectedAnswer != "" {
+		return answer == request.ExpectedAnswer
+	}
+	if len(settings.Answers) == 0 {
+		return answer != ""
+	}
+	for _, accepted := range settings.Answers {
+		if answer == normalizeAnswer(accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseJoinCallbackArgs(args callbackArgs) (action string, userID int64, ok bool) {
+	action = args.string(0)
+	if action != joinActionApprove && action != joinActionDecline {
+		return "", 0, false
+	}
+	userID, ok = args.int64(1)
+	if !ok {
+		return "", 0, false
+	}
+	return action, userID, true
+}
+
+func (app *App) createJoinReviewMarkup(userID int64) map[string]any {
+	return map[string]any{
+		"inline_keyboard": [][]map[string]string{
+			{
+				app.callbackButton("Принять", joinCallbackPrefix, joinActionApprove, userID),
+				app.callbackButton("Отклонить", joinCallbackPrefix, joinActionDecline, userID),
+			},
+		},
+	}
+}
+
+func createJoinReviewMessage(request *PendingJoinRequest, answer string) string {
+	return fmt.Sprintf("Заявка на вступление от %s (id %d)\n\nВопрос: %s\nОтвет: %s",
+		formatUserMention(&request.User), request.User.ID, html.EscapeString(request.Question), html.EscapeString(answer))
+}
+
+func (app *App) handleChatJoinRequest(joinRequest *ChatJoinRequest) {
+	question, answer := createJoinRequestQuestion(&app.config.JoinRequests)
+	request := PendingJoinRequest{
+		ChatID:         joinRequest.Chat.ID,
+		User:           joinRequest.From,
+		UserChatID:     joinRequest.UserChatID,
+		Question:       question,
+		ExpectedAnswer: answer,
+	}
+	app.saveJoinRequest(request)
+
+	_, err := app.telegram.sendMessage(map[string]any{
+		"chat_id": request.UserChatID,
+		"text":    "Здравствуйте! Чтобы вступить в группу «Мыльная Мама», ответьте, пожалуйста, на вопрос:\n\n" + question,
+	})
+	if err != nil {
+		slog.Error("Error sending join request question", "user_id", request.User.ID, "error", err)
+	}
+}
+
+func (app *App) handleJoinRequestAnswer(message *Message) {
+	request, ok := app.joinRequest(message.From.ID)
+	if !ok {
+		return
+	}
+
+	if app.config.JoinRequests.Review == reviewAdmin {
+		request.Answered = true
+		app.saveJoinRequest(request)
+		_, err := app.telegram.sendMessage(map[string]any{
+			"chat_id":      app.config.AdminChatID,
+			"text":         createJoinReviewMessage(&request, message.Text),
+			"parse_mode":   "HTML",
+			"reply_markup": app.createJoinReviewMarkup(request.User.ID),
+		})
+		if err != nil {
+			slog.Error("Error posting join request for review", "user_id", request.User.ID, "error", err)
+			return
+		}
+		app.notifyApplicant(&request, "Спасибо! Заявка передана администраторам, скоро её рассмотрят.")
+		return
+	}
+
+	// The request stays pending when Telegram fails, the applicant's next answer retries it
+	var handled bool
+	if isAcceptedAnswer(&request, &app.config.JoinRequests, message.Text) {
+		handled = app.approveJoinRequest(&request)
+	} else {
+		handled = app.declineJoinRequest(&request)
+	}
+	if handled {
+		app.deleteJoinRequest(request.User.ID)
+	}
+}
+
+func (app *App) approveJoinRequest(request *PendingJoinRequest) bool {
+	if err := app.telegram.approveChatJoinRequest(request.ChatID, request.User.ID); err != nil {
+		slog.Error("Error approving join request", "user_id", request.User.ID, "error", err)
+		return false
+	}
+	app.notifyApplicant(request, "Спасибо! Заявка одобрена, добро пожаловать в группу.")
+	return true
+}
+
+func (app *App) declineJoinRequest(request *PendingJoinRequest) bool {
+	if err := app.telegram.declineChatJoinRequest(request.ChatID, request.User.ID); err != nil {
+		slog.Error("Error declining join request", "user_id", request.User.ID, "error", err)
+		return false
+	}
+	app.notifyApplicant(request, "К сожалению, заявка отклонена.")
+	return true
+}
+
+func (app *App) notifyApplicant(request *PendingJoinRequest, text string) {
+	_, err := app.telegram.sendMessage(map[string]any{
+		"chat_id": request.UserChatID,
+		"text":    text,
+	})
+	if err != nil {
+		slog.Error("Error notifying applicant", "user_id", request.User.ID, "error", err)
+	}
+}
+
+func (app *App) handleJoinReviewCallback(query *CallbackQuery, args callbackArgs) string {
+	action, userID, ok := parseJoinCallbackArgs(args)
+	if !ok || query.Message == nil || query.Message.Chat.ID != app.config.AdminChatID {
+		return ""
+	}
+
+	request, ok := app.joinRequest(userID)
+	if !ok {
+		return "Заявка уже рассмотрена"
+	}
+
+	status := "Принята"
+	if action == joinActionApprove {
+		ok = app.approveJoinRequest(&request)
+	} else {
+		status = "Отклонена"
+		ok = app.declineJoinRequest(&request)
+	}
+	if !ok {
+		return "Не получилось, попробуйте ещё раз"
+	}
+	app.deleteJoinRequest(userID)
+
+	err := app.telegram.editMessageText(map[string]any{
+		"chat_id":    query.Message.Chat.ID,
+		"message_id": query.Message.MessageID,
+		"text":       fmt.Sprintf("%s\n\n%s: %s", html.EscapeString(query.Message.Text), status, formatUserMention(&query.From)),
+		"parse_mode": "HTML",
+	})
+	if err != nil {
+		slog.Error("Error updating join request review", "user_id", userID, "error", err)
+	}
+	return status
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func joinRequestsTestConfig(settings JoinRequests) func(config *Config) {
+	return func(config *Config) {
+		config.JoinRequests = settings
+		config.JoinRequests.Enabled = true
+	}
+}
+
+func createTestJoinRequest() *Update {
+	return &Update{
+		ChatJoinRequest: &ChatJoinRequest{
+			Chat:       Chat{ID: 123456789},
+			From:       User{ID: 111222333, FirstName: "Jane", Username: "janesmith"},
+			UserChatID: 111222333,
+		},
+	}
+}
+
+func createTestPrivateMessage(text string) *Update {
+	return &Update{
+		Message: &Message{
+			Text: text,
+			Chat: Chat{ID: 111222333, Type: "private"},
+			From: User{ID: 111222333, FirstName: "Jane", Username: "janesmith"},
+		},
+	}
+}
+
+func TestParseJoinCallbackArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           callbackArgs
+		expectedAction string
+		expectedUserID int64
+		expectedOk     bool
+	}{
+		{
+			name:           "approve",
+			args:           callbackArgs{joinActionApprove, "1u7vp9"},
+			expectedAction: joinActionApprove,
+			expectedUserID: 111222333,
+			expectedOk:     true,
+		},
+		{
+			name:           "decline",
+			args:           callbackArgs{joinActionDecline, "16"},
+			expectedAction: joinActionDecline,
+			expectedUserID: 42,
+			expectedOk:     true,
+		},
+		{
+			name: "unknown action",
+			args: callbackArgs{"ban", "16"},
+		},
+		{
+			name: "missing user id",
+			args: callbackArgs{joinActionApprove},
+		},
+		{
+			name: "malformed user id",
+			args: callbackArgs{joinActionApprove, "a:b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, userID, ok := parseJoinCallbackArgs(tt.args)
+			if ok != tt.expectedOk || action != tt.expectedAction || userID != tt.expectedUserID {
+				t.Errorf("Expected (%s, %d, %v), got (%s, %d, %v)",
+					tt.expectedAction, tt.expectedUserID, tt.expectedOk, action, userID, ok)
+			}
+		})
+	}
+}
+
+func TestIsAcceptedAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  PendingJoinRequest
+		settings JoinRequests
+		answer   string
+		expected bool
+	}{
+		{
+			name:     "correct captcha",
+			request:  PendingJoinRequest{ExpectedAnswer: "7"},
+			answer:   " 7 ",
+			expected: true,
+		},
+		{
+			name:     "wrong captcha",
+			request:  PendingJoinRequest{ExpectedAnswer: "7"},
+			answer:   "8",
+			expected: false,
+		},
+		{
+			name:     "question without accepted answers",
+			settings: JoinRequests{Challenge: challengeQuestion},
+			answer:   "Из инстаграма",
+			expected: true,
+		},
+		{
+			name:     "question with matching answer",
+			settings: JoinRequests{Challenge: challengeQuestion, Answers: []string{"Инстаграм", "Ярмарка"}},
+			answer:   "ярмарка",
+			expected: true,
+		},
+		{
+			name:     "question with wrong answer",
+			settings: JoinRequests{Challenge: challengeQuestion, Answers: []string{"Инстаграм"}},
+			answer:   "не знаю",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isAcceptedAnswer(&tt.request, &tt.settings, tt.answer)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestJoinRequestAutoApprove(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeCaptcha, Review: reviewAuto}))
+
+	app.handleTelegramUpdate(createTestJoinRequest())
+
+	request, ok := app.joinRequest(111222333)
+	if !ok {
+		t.Fatal("Expected join request to be pending")
+	}
+	questions := api.callsTo("sendMessage")
+	if len(questions) != 1 || !strings.Contains(questions[0].Params["text"].(string), "Сколько будет") {
+		t.Fatalf("Expected captcha to be sent to the applicant, got %+v", questions)
+	}
+
+	app.handleTelegramUpdate(createTestPrivateMessage(request.ExpectedAnswer))
+
+	if len(api.callsTo("approveChatJoinRequest")) != 1 {
+		t.Error("Expected join request to be approved")
+	}
+	if _, ok := app.joinRequest(111222333); ok {
+		t.Error("Expected join request to be removed after the answer")
+	}
+}
+
+func TestJoinRequestAutoDecline(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeCaptcha, Review: reviewAuto}))
+
+	app.handleTelegramUpdate(createTestJoinRequest())
+	app.handleTelegramUpdate(createTestPrivateMessage("не число"))
+
+	if len(api.callsTo("declineChatJoinRequest")) != 1 {
+		t.Error("Expected join request to be declined")
+	}
+	if len(api.callsTo("approveChatJoinRequest")) != 0 {
+		t.Error("Expected join request to not be approved")
+	}
+}
+
+func TestJoinRequestAdminReview(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{
+		Challenge: challengeQuestion,
+		Question:  "Как вы узнали о нас?",
+		Review:    reviewAdmin,
+	}))
+
+	app.handleTelegramUpdate(createTestJoinRequest())
+	app.handleTelegramUpdate(createTestPrivateMessage("С ярмарки"))
+
+	var review *recordedCall
+	for _, call := range api.callsTo("sendMessage") {
+		if call.Params["chat_id"] == float64(555) {
+			review = &call
+		}
+	}
+	if review == nil {
+		t.Fatal("Expected join request to be posted to the admin chat")
+	}
+	if !strings.Contains(review.Params["text"].(string), "С ярмарки") {
+		t.Errorf("Expected review to contain the answer, got %s", review.Params["text"])
+	}
+
+	// A second message from the applicant must not repost the request
+	app.handleTelegramUpdate(createTestPrivateMessage("Ещё раз"))
+	if len(api.callsTo("sendMessage")) != 3 {
+		t.Errorf("Expected 3 messages (question, review, notice), got %d", len(api.callsTo("sendMessage")))
+	}
+
+	app.handleTelegramUpdate(&Update{
+		CallbackQuery: &CallbackQuery{
+			ID:      "callback",
+			From:    User{ID: 1, FirstName: "Admin"},
+			Message: &Message{MessageID: 10, Chat: Chat{ID: 555}, Text: "Заявка"},
+			Data:    app.callbacks.data(joinCallbackPrefix, joinActionApprove, int64(111222333)),
+		},
+	})
+
+	if len(api.callsTo("approveChatJoinRequest")) != 1 {
+		t.Error("Expected join request to be approved by the admin")
+	}
+	if len(api.callsTo("answerCallbackQuery")) != 1 {
+		t.Error("Expected callback query to be answered")
+	}
+	if len(api.callsTo("editMessageText")) != 1 {
+		t.Error("Expected review message to be updated")
+	}
+}
+
+func TestJoinReviewCallbackFromOtherChat(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeQuestion, Review: reviewAdmin}))
+
+	app.handleTelegramUpdate(createTestJoinRequest())
+	app.handleTelegramUpdate(&Update{
+		CallbackQuery: &CallbackQuery{
+			ID:      "callback",
+			Message: &Message{MessageID: 10, Chat: Chat{ID: 999}},
+			Data:    app.callbacks.data(joinCallbackPrefix, joinActionApprove, int64(111222333)),
+		},
+	})
+
+	if len(api.callsTo("approveChatJoinRequest")) != 0 {
+		t.Error("Expected callback from another chat to be ignored")
+	}
+	if _, ok := app.joinRequest(111222333); !ok {
+		t.Error("Expected join request to stay pending")
+	}
+}
+
+func TestJoinRequestDisabled(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{}))
+	app.config.JoinRequests.Enabled = false
+
+	app.handleTelegramUpdate(createTestJoinRequest())
+
+	if len(api.recordedCalls()) != 0 {
+		t.Errorf("Expected no API calls, got %d", len(api.recordedCalls()))
+	}
+}
+
+func TestJoinRequestSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	app, _ := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeCaptcha, Review: reviewAuto}))
+	app.store = store
+	app.handleTelegramUpdate(createTestJoinRequest())
+	request, _ := app.joinRequest(111222333)
+
+	reopened, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	restarted, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeCaptcha, Review: reviewAuto}))
+	restarted.store = reopened
+	restarted.handleTelegramUpdate(createTestPrivateMessage(request.ExpectedAnswer))
+
+	if len(api.callsTo("approveChatJoinRequest")) != 1 {
+		t.Error("Expected the answer to be accepted after a restart")
+	}
+}
+
+func TestJoinReviewKeepsRequestWhenApproveFails(t *testing.T) {
+	app, api := newTestApp(t, joinRequestsTestConfig(JoinRequests{Challenge: challengeQuestion, Review: reviewAdmin}))
+	app.handleTelegramUpdate(createTestJoinRequest())
+	app.handleTelegramUpdate(createTestPrivateMessage("С ярмарки"))
+	review := &Update{
+		CallbackQuery: &CallbackQuery{
+			ID:      "callback",
+			From:    User{ID: 1, FirstName: "Admin"},
+			Message: &Message{MessageID: 10, Chat: Chat{ID: 555}, Text: "Заявка"},
+			Data:    app.callbacks.data(joinCallbackPrefix, joinActionApprove, int64(111222333)),
+		},
+	}
+
+	api.queueResponse("approveChatJoinRequest", `{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 1"}`)
+	app.handleTelegramUpdate(review)
+
+	if len(api.callsTo("editMessageText")) != 0 {
+		t.Error("Expected the review message to stay open after a failure")
+	}
+	answers := api.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params["text"] != "Не получилось, попробуйте ещё раз" {
+		t.Errorf("Expected the admin to be told about the failure, got %+v", answers)
+	}
+	if _, ok := app.joinRequest(111222333); !ok {
+		t.Fatal("Expected join request to stay pending")
+	}
+
+	app.handleTelegramUpdate(review)
+
+	if len(api.callsTo("approveChatJoinRequest")) != 2 || len(api.callsTo("editMessageText")) != 1 {
+		t.Error("Expected the retry to approve the request")
+	}
+	if _, ok := app.joinRequest(111222333); ok {
+		t.Error("Expected join request to be removed once approved")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+)
+
+func splitBots(members []User) (humans []User, bots []User) {
+	for _, member := range members {
+		if member.IsBot {
+			bots = append(bots, member)
+		} else {
+			humans = append(humans, member)
+		}
+	}
+	return humans, bots
+}
+
+func isBotAllowed(bot *User, settings *Bots) bool {
+	for _, username := range settings.Allowed {
+		if strings.EqualFold(strings.TrimPrefix(username, "@"), bot.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAdminStatus(status string) bool {
+	return status == "creator" || status == "administrator"
+}
+
+func (app *App) isChatAdmin(chatID int64, userID int64) bool {
+	member, err := app.telegram.getChatMember(chatID, userID)
+	if err != nil {
+		slog.Error("Error getting chat member", "chat_id", chatID, "user_id", userID, "error", err)
+		// Don't kick anything when the adder can't be checked
+		return true
+	}
+	return isAdminStatus(member.Status)
+}
+
+func (app *App) kickBot(chatID int64, bot *User) {
+	if err := app.telegram.banChatMember(chatID, bot.ID); err != nil {
+		slog.Error("Error kicking bot", "bot", bot.Username, "error", err)
+		return
+	}
+	// Unban right away so an admin can still add the bot later
+	if err := app.telegram.unbanChatMember(chatID, bot.ID); err != nil {
+		slog.Error("Error unbanning kicked bot", "bot", bot.Username, "error", err)
+	}
+	slog.Info("Kicked bot added by non-admin", "bot", bot.Username)
+}
+
+func (app *App) handleNewBots(message *Message, bots []User) {
+	settings := &app.config.Bots
+	if !settings.KickUnauthorized {
+		return
+	}
+
+	var unauthorized []User
+	for _, bot := range bots {
+		if !isBotAllowed(&bot, settings) {
+			unauthorized = append(unauthorized, bot)
+		}
+	}
+	if len(unauthorized) == 0 || app.isChatAdmin(message.Chat.ID, message.From.ID) {
+		return
+	}
+
+	for _, bot := range unauthorized {
+		app.kickBot(message.Chat.ID, &bot)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func botsTestConfig(settings Bots) func(config *Config) {
+	return func(config *Config) {
+		config.Bots = settings
+	}
+}
+
+func createTestMembersJoin(members ...User) *Update {
+	return &Update{
+		Message: &Message{
+			Chat:           Chat{ID: 123456789, Type: "supergroup"},
+			From:           User{ID: 987654321, FirstName: "John"},
+			NewChatMembers: members,
+		},
+	}
+}
+
+var (
+	testHelperBot = User{ID: 1001, IsBot: true, FirstName: "Helper", Username: "HelperBot"}
+	testSpamBot   = User{ID: 1002, IsBot: true, FirstName: "Spam", Username: "spam_bot"}
+	testHuman     = User{ID: 111222333, FirstName: "Jane", Username: "janesmith"}
+)
+
+func TestSplitBots(t *testing.T) {
+	humans, bots := splitBots([]User{testHelperBot, testHuman, testSpamBot})
+
+	if len(humans) != 1 || humans[0].ID != testHuman.ID {
+		t.Errorf("Expected only Jane among humans, got %+v", humans)
+	}
+	if len(bots) != 2 {
+		t.Errorf("Expected 2 bots, got %d", len(bots))
+	}
+}
+
+func TestIsBotAllowed(t *testing.T) {
+	settings := &Bots{Allowed: []string{"@helperbot"}}
+
+	tests := []struct {
+		name     string
+		bot      User
+		expected bool
+	}{
+		{
+			name:     "allowed bot with different case",
+			bot:      testHelperBot,
+			expected: true,
+		},
+		{
+			name:     "bot not in allowlist",
+			bot:      testSpamBot,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isBotAllowed(&tt.bot, settings)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewBotIsNotGreeted(t *testing.T) {
+	app, api := newTestApp(t, botsTestConfig(Bots{}))
+
+	app.handleTelegramUpdate(createTestMembersJoin(testHelperBot))
+
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("Expected bot to not be greeted")
+	}
+}
+
+func TestNewBotGreetedWhenEnabled(t *testing.T) {
+	app, api := newTestApp(t, botsTestConfig(Bots{Greet: true}))
+
+	app.handleTelegramUpdate(createTestMembersJoin(testHelperBot))
+
+	if len(api.callsTo("sendMessage")) != 1 {
+		t.Error("Expected bot to be greeted")
+	}
+}
+
+func TestNewMembersGreetingSkipsBots(t *testing.T) {
+	app, api := newTestApp(t, botsTestConfig(Bots{}))
+
+	app.handleTelegramUpdate(createTestMembersJoin(testHelperBot, testHuman))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 welcome message, got %d", len(calls))
+	}
+	text := calls[0].Params["text"].(string)
+	if !strings.Contains(text, "Привет, @janesmith!") {
+		t.Errorf("Expected welcome for Jane only, got %s", text)
+	}
+}
+
+func TestKickUnauthorizedBot(t *testing.T) {
+	tests := []struct {
+		name           string
+		bot            User
+		adderStatus    string
+		expectedChecks int
+		expectedBans   int
+	}{
+		{
+			name:           "bot added by member",
+			bot:            testSpamBot,
+			adderStatus:    "member",
+			expectedChecks: 1,
+			expectedBans:   1,
+		},
+		{
+			name:           "bot added by admin",
+			bot:            testSpamBot,
+			adderStatus:    "administrator",
+			expectedChecks: 1,
+			expectedBans:   0,
+		},
+		{
+			name:           "allowed bot added by member",
+			bot:            testHelperBot,
+			adderStatus:    "member",
+			expectedChecks: 0,
+			expectedBans:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, api := newTestApp(t, botsTestConfig(Bots{Allowed: []string{"helperbot"}, KickUnauthorized: true}))
+			api.setResponse("getChatMember", `{"ok": true, "result": {"status": "`+tt.adderStatus+`"}}`)
+
+			app.handleTelegramUpdate(createTestMembersJoin(tt.bot))
+
+			if len(api.callsTo("getChatMember")) != tt.expectedChecks {
+				t.Errorf("Expected %d admin checks, got %d", tt.expectedChecks, len(api.callsTo("getChatMember")))
+			}
+			if len(api.callsTo("banChatMember")) != tt.expectedBans {
+				t.Errorf("Expected %d bans, got %d", tt.expectedBans, len(api.callsTo("banChatMember")))
+			}
+			if len(api.callsTo("unbanChatMember")) != tt.expectedBans {
+				t.Errorf("Expected %d unbans, got %d", tt.expectedBans, len(api.callsTo("unbanChatMember")))
+			}
+		})
+	}
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"log/slog"
-	"net/http"
 	"strings"
+	"time"
 )
 
 func (app *App) isNewMemberJoined(message *Message) bool {
@@ -14,102 +15,163 @@ func (app *App) isNewMemberJoined(message *Message) bool {
 		message.Chat.ID == app.config.ChatID
 }
 
-func formatUserMention(user *User) string {
-	userMention := user.FirstName
+func formatUserName(user *User) string {
+	userName := user.FirstName
 	if user.LastName != "" {
-		userMention += " " + user.LastName
+		userName += " " + user.LastName
 	}
+	return userName
+}
 
-	notificationMention := userMention
+// formatUserMention renders a mention for messages sent with HTML parse mode.
+// Users without a username are mentioned with a tg://user link so they are notified too.
+func formatUserMention(user *User) string {
 	if user.Username != "" {
-		notificationMention = "@" + user.Username
+		return "@" + html.EscapeString(user.Username)
 	}
-
-	return notificationMention
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>`, user.ID, html.EscapeString(formatUserName(user)))
 }
 
-func buildSendMessageUrl(token string) string {
-	return fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
-}
-
-func createWelcomeMessageForNewMembers(newMembers []User) string {
+func joinUserMentions(users []User) string {
 	// Format all user mentions
 	var mentions []string
-	for _, member := range newMembers {
-		mentions = append(mentions, formatUserMention(&member))
+	for _, user := range users {
+		mentions = append(mentions, formatUserMention(&user))
 	}
 
 	// Join all mentions with commas and "and" for the last one
-	var userMentions string
 	if len(mentions) == 1 {
-		userMentions = mentions[0]
-	} else if len(mentions) == 2 {
-		userMentions = mentions[0] + " и " + mentions[1]
-	} else {
-		userMentions = strings.Join(mentions[:len(mentions)-1], ", ") + " и " + mentions[len(mentions)-1]
+		return mentions[0]
 	}
+	return strings.Join(mentions[:len(mentions)-1], ", ") + " и " + mentions[len(mentions)-1]
+}
 
+func createWelcomeMessageForNewMembers(newMembers []User) string {
+	userMentions := joinUserMentions(newMembers)
 	return fmt.Sprintf("Привет, %s!\n\nВы пришли в мастерскую крафтового мыла «Мыльная Мама», которая специализируется на натуральной и безопасной продукции. Делаем своими руками, из своих трав и по своим рецептам.", userMentions)
 }
 
 func createButtonsMarkup(links *Links) map[string]any {
-	return map[string]any{
-		"inline_keyboard": [][]map[string]string{
-			{
-				{
-					"text": "Как сделать заказ",
-					"url":  links.Prices,
-				},
-			},
-			{
-				{
-					"text": "Что такое крафтовое мыло",
-					"url":  links.Soap,
-				},
-			},
-			{
-				{
-					"text": "Что такое гидролат",
-					"url":  links.Distillate,
-				},
-			},
+	return createCustomButtonsMarkup(linkButtons(links))
+}
+
+func createCustomButtonsMarkup(buttons []Button) map[string]any {
+	var keyboard [][]map[string]string
+	for _, button := range buttons {
+		keyboard = append(keyboard, []map[string]string{
 			{
-				{
-					"text": "Что такое убтан",
-					"url":  links.Ubtan,
-				},
+				"text": button.Text,
+				"url":  button.Url,
 			},
-		},
+		})
+	}
+	return map[string]any{
+		"inline_keyboard": keyboard,
 	}
 }
 
-func (app *App) buildNewMembersMessagePayload(newMembers []User) *strings.Reader {
+func (app *App) newMembersMessageParams(topic WelcomeTopic, newMembers []User) map[string]any {
+	text := createWelcomeMessageForNewMembers(newMembers)
+	if topic.Template != "" {
+		text = renderMentionsTemplate(topic.Template, newMembers)
+	}
+	links := app.links()
+	buttons := linkButtons(&links)
+	if len(topic.Buttons) > 0 {
+		buttons = topic.Buttons
+	}
+
 	payload := map[string]any{
 		"chat_id":      app.config.ChatID,
-		"text":         createWelcomeMessageForNewMembers(newMembers),
-		"reply_markup": createButtonsMarkup(&app.config.Links),
+		"text":         text,
+		"parse_mode":   "HTML",
+		"reply_markup": createCustomButtonsMarkup(app.trackButtons(buttons, app.config.ChatID)),
+	}
+	setMessageThreadID(payload, topic.ID)
+	return payload
+}
+
+func (app *App) sendWelcome(params map[string]any) error {
+	switch len(app.config.Welcome.Media) {
+	case 0:
+		_, err := app.telegram.sendMessage(params)
+		return err
+	case 1:
+		return app.sendWelcomePhoto(params, app.config.Welcome.Media[0])
+	default:
+		return app.sendWelcomeAlbum(params, app.config.Welcome.Media)
+	}
+}
+
+// sendNewMembersMessage posts the full welcome to the first welcome topic only,
+// the other topics get just their own template so a newcomer isn't greeted several times
+func (app *App) sendNewMembersMessage(newMembers []User) error {
+	topics := app.welcomeTopics()
+	var errs []error
+	params := app.newMembersMessageParams(topics[0], newMembers)
+	if app.config.Welcome.Private {
+		app.addGiftButton(params)
+	}
+	if err := app.sendWelcome(params); err != nil {
+		errs = append(errs, fmt.Errorf("topic %d: %w", topics[0].ID, err))
 	}
-	if app.config.ThreadID > 1 {
-		payload["message_thread_id"] = app.config.ThreadID
+	for _, topic := range topics[1:] {
+		if _, err := app.telegram.sendMessage(app.topicNoteParams(topic, newMembers)); err != nil {
+			errs = append(errs, fmt.Errorf("topic %d: %w", topic.ID, err))
+		}
 	}
-	jsonData, _ := json.Marshal(payload)
-	return strings.NewReader(string(jsonData))
+	return errors.Join(errs...)
 }
 
-func sendMessage(url string, payload *strings.Reader) {
-	resp, err := http.Post(url, "application/json", payload)
-	if err != nil {
-		slog.Error("Error sending message", "error", err)
+func (app *App) handleNewMembers(message *Message) {
+	humans, bots := splitBots(message.NewChatMembers)
+	app.handleNewBots(message, bots)
+
+	if app.config.Bots.Greet {
+		humans = message.NewChatMembers
+	}
+	if len(humans) == 0 {
 		return
 	}
-	defer resp.Body.Close()
-	slog.Info("Sent message", "status", resp.Status)
+
+	newcomers, returning := app.recordJoins(humans, time.Now())
+	if app.config.Welcome.Private {
+		newcomers = app.sendPrivateWelcomes(newcomers)
+	}
+	if len(newcomers) > 0 {
+		if err := app.sendNewMembersMessage(newcomers); err != nil {
+			slog.Error("Error sending message", "error", err)
+		}
+	}
+	if len(returning) > 0 {
+		app.sendReturningMembersMessage(returning)
+	}
 }
 
 func (app *App) handleTelegramUpdate(update *Update) {
-	if app.isNewMemberJoined(update.Message) {
-		url := buildSendMessageUrl(app.config.Token)
-		payload := app.buildNewMembersMessagePayload(update.Message.NewChatMembers)
-		sendMessage(url, payload)
+	switch {
+	case app.isJoinRequestForGroup(update.ChatJoinRequest):
+		app.handleChatJoinRequest(update.ChatJoinRequest)
+	case update.CallbackQuery != nil:
+		app.handleCallbackQuery(update.CallbackQuery)
+	case update.InlineQuery != nil:
+		app.handleInlineQuery(update.InlineQuery)
+	case app.isChatMemberJoined(update.ChatMember):
+		app.handleChatMemberJoined(update.ChatMember)
+	case app.isNewMemberJoined(update.Message):
+		app.handleNewMembers(update.Message)
+	case isCommand(update.Message) && app.commands.dispatch(update.Message):
+	case app.isTrackingNumberReply(update.Message):
+		app.handleTrackingNumberReply(update.Message)
+	case app.isAnnouncementMessage(update.Message):
+		app.handleAnnouncementMessage(update.Message)
+	case app.isBroadcastMessage(update.Message):
+		app.handleBroadcastMessage(update.Message)
+	case app.isOrderMessage(update.Message):
+		app.handleOrderMessage(update.Message)
+	case app.isJoinRequestAnswer(update.Message):
+		app.handleJoinRequestAnswer(update.Message)
+	case app.isFaqQuestion(update.Message):
+		app.handleFaqQuestion(update.Message)
 	}
 }
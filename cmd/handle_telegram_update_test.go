@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io"
 	"strings"
 	"testing"
 )
@@ -83,7 +82,7 @@ func TestFormatUserMention(t *testing.T) {
 				ID:        123456789,
 				FirstName: "John",
 			},
-			expected: "John",
+			expected: `<a href="tg://user?id=123456789">John</a>`,
 		},
 		{
 			name: "user with first and last name",
@@ -92,7 +91,7 @@ func TestFormatUserMention(t *testing.T) {
 				FirstName: "John",
 				LastName:  "Doe",
 			},
-			expected: "John Doe",
+			expected: `<a href="tg://user?id=123456789">John Doe</a>`,
 		},
 		{
 			name: "user with username",
@@ -113,39 +112,29 @@ func TestFormatUserMention(t *testing.T) {
 			},
 			expected: "@johndoe",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatUserMention(&tt.user)
-			if result != tt.expected {
-				t.Errorf("Expected %s, got %s", tt.expected, result)
-			}
-		})
-	}
-}
-
-func TestBuildSendMessageUrl(t *testing.T) {
-	tests := []struct {
-		name     string
-		token    string
-		expected string
-	}{
 		{
-			name:     "valid token",
-			token:    "123456789:ABCdefGHIjklMNOpqrsTUVwxyz",
-			expected: "https://api.telegram.org/bot123456789:ABCdefGHIjklMNOpqrsTUVwxyz/sendMessage",
+			name: "user with html in name",
+			user: User{
+				ID:        123456789,
+				FirstName: "<b>John</b>",
+				LastName:  "& Co",
+			},
+			expected: `<a href="tg://user?id=123456789">&lt;b&gt;John&lt;/b&gt; &amp; Co</a>`,
 		},
 		{
-			name:     "empty token",
-			token:    "",
-			expected: "https://api.telegram.org/bot/sendMessage",
+			name: "user with markdown characters in name",
+			user: User{
+				ID:        123456789,
+				FirstName: "_John_",
+				LastName:  "\"Doe\"",
+			},
+			expected: `<a href="tg://user?id=123456789">_John_ &#34;Doe&#34;</a>`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := buildSendMessageUrl(tt.token)
+			result := formatUserMention(&tt.user)
 			if result != tt.expected {
 				t.Errorf("Expected %s, got %s", tt.expected, result)
 			}
@@ -188,7 +177,7 @@ func TestCreateWelcomeMessageForNewMembers(t *testing.T) {
 			newMembers: []User{
 				{ID: 111222333, FirstName: "Jane", LastName: "Smith"},
 			},
-			expected: "Привет, Jane Smith!\n\nВы пришли в мастерскую крафтового мыла «Мыльная Мама», которая специализируется на натуральной и безопасной продукции. Делаем своими руками, из своих трав и по своим рецептам.",
+			expected: "Привет, <a href=\"tg://user?id=111222333\">Jane Smith</a>!\n\nВы пришли в мастерскую крафтового мыла «Мыльная Мама», которая специализируется на натуральной и безопасной продукции. Делаем своими руками, из своих трав и по своим рецептам.",
 		},
 	}
 
@@ -260,8 +249,9 @@ func TestCreateButtonsMarkup(t *testing.T) {
 	}
 }
 
-func TestBuildNewMembersMessagePayload(t *testing.T) {
+func TestNewMembersMessageParams(t *testing.T) {
 	app := &App{
+		store: newMemoryStore(),
 		config: &Config{
 			ChatID:   123456789,
 			ThreadID: 2, // Set to > 1 to trigger message_thread_id inclusion
@@ -278,41 +268,32 @@ func TestBuildNewMembersMessagePayload(t *testing.T) {
 		{ID: 111222333, FirstName: "Jane", LastName: "Smith", Username: "janesmith"},
 	}
 
-	result := app.buildNewMembersMessagePayload(newMembers)
-
-	if result == nil {
-		t.Error("Expected result to be non-nil")
-		return
-	}
+	params := app.newMembersMessageParams(app.defaultWelcomeTopic(), newMembers)
 
-	// Read the content to verify it's valid JSON
-	contentBytes, err := io.ReadAll(result)
-	if err != nil {
-		t.Errorf("Error reading result: %v", err)
-		return
+	if params["chat_id"] != int64(123456789) {
+		t.Errorf("Expected chat_id 123456789, got %v", params["chat_id"])
 	}
-	content := string(contentBytes)
 
-	// Check that content contains expected fields
-	if !strings.Contains(content, "123456789") {
-		t.Error("Expected content to contain chat_id")
+	if !strings.Contains(params["text"].(string), "Привет") {
+		t.Error("Expected text to contain welcome message")
 	}
 
-	if !strings.Contains(content, "Привет") {
-		t.Error("Expected content to contain welcome message")
+	if _, ok := params["reply_markup"].(map[string]any)["inline_keyboard"]; !ok {
+		t.Error("Expected reply_markup to contain inline_keyboard")
 	}
 
-	if !strings.Contains(content, "inline_keyboard") {
-		t.Error("Expected content to contain inline_keyboard")
+	if params["message_thread_id"] != int64(2) {
+		t.Errorf("Expected message_thread_id 2, got %v", params["message_thread_id"])
 	}
 
-	if !strings.Contains(content, "message_thread_id") {
-		t.Error("Expected content to contain message_thread_id")
+	if params["parse_mode"] != "HTML" {
+		t.Error("Expected HTML parse mode")
 	}
 }
 
-func TestBuildNewMembersMessagePayloadWithoutThreadID(t *testing.T) {
+func TestNewMembersMessageParamsWithoutThreadID(t *testing.T) {
 	app := &App{
+		store: newMemoryStore(),
 		config: &Config{
 			ChatID:   123456789,
 			ThreadID: 0, // No thread ID
@@ -329,23 +310,10 @@ func TestBuildNewMembersMessagePayloadWithoutThreadID(t *testing.T) {
 		{ID: 111222333, FirstName: "Jane", LastName: "Smith", Username: "janesmith"},
 	}
 
-	result := app.buildNewMembersMessagePayload(newMembers)
-
-	if result == nil {
-		t.Error("Expected result to be non-nil")
-		return
-	}
-
-	// Read the content to verify it's valid JSON
-	contentBytes, err := io.ReadAll(result)
-	if err != nil {
-		t.Errorf("Error reading result: %v", err)
-		return
-	}
-	content := string(contentBytes)
+	params := app.newMembersMessageParams(app.defaultWelcomeTopic(), newMembers)
 
-	// Check that content does NOT contain message_thread_id when ThreadID is 0
-	if strings.Contains(content, "message_thread_id") {
-		t.Error("Expected content to NOT contain message_thread_id when ThreadID is 0")
+	// Check that params do NOT contain message_thread_id when ThreadID is 0
+	if _, ok := params["message_thread_id"]; ok {
+		t.Error("Expected params to NOT contain message_thread_id when ThreadID is 0")
 	}
 }
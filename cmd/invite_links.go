@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Telegram limits for createChatInviteLink
+	maxInviteLinkNameLength = 32
+	maxInviteLinkMembers    = 99999
+
+	inviteDaysOption    = "дней="
+	inviteMembersOption = "мест="
+	inviteUsage         = "Создать ссылку: /invite <название> [дней=N] [мест=N], например /invite Ярмарка на Тверской дней=7 мест=100"
+)
+
+// InviteLink is an invite link to the group created with /invite, joins through it are counted
+type InviteLink struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Url         string    `json:"url"`
+	Author      User      `json:"author"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	MemberLimit int       `json:"member_limit,omitempty"`
+	Revoked     bool      `json:"revoked"`
+	Joins       int       `json:"joins"`
+}
+
+// parseInviteArgs reads "<name> [дней=N] [мест=N]", numbers without an option are part of the name
+func parseInviteArgs(args string) (name string, days int, limit int, ok bool) {
+	var words []string
+	for _, field := range strings.Fields(args) {
+		var err error
+		switch {
+		case strings.HasPrefix(field, inviteDaysOption):
+			days, err = strconv.Atoi(strings.TrimPrefix(field, inviteDaysOption))
+		case strings.HasPrefix(field, inviteMembersOption):
+			limit, err = strconv.Atoi(strings.TrimPrefix(field, inviteMembersOption))
+		default:
+			words = append(words, field)
+		}
+		if err != nil {
+			return "", 0, 0, false
+		}
+	}
+	name = strings.Join(words, " ")
+	if name == "" || len([]rune(name)) > maxInviteLinkNameLength {
+		return "", 0, 0, false
+	}
+	if days < 0 || limit < 0 || limit > maxInviteLinkMembers {
+		return "", 0, 0, false
+	}
+	return name, days, limit, true
+}
+
+func (app *App) handleInviteCommand(message *Message, args string) {
+	name, days, limit, ok := parseInviteArgs(args)
+	if !ok {
+		app.replyText(message, fmt.Sprintf("%s\nНазвание — до %d символов, мест — до %d.", inviteUsage, maxInviteLinkNameLength, maxInviteLinkMembers))
+		return
+	}
+
+	now := time.Now()
+	link := InviteLink{Name: name, Author: message.From, CreatedAt: now, MemberLimit: limit}
+	params := map[string]any{
+		"chat_id": app.config.ChatID,
+		"name":    name,
+	}
+	if days > 0 {
+		link.ExpiresAt = now.AddDate(0, 0, days)
+		params["expire_date"] = link.ExpiresAt.Unix()
+	}
+	if limit > 0 {
+		params["member_limit"] = limit
+	}
+	created, err := app.telegram.createChatInviteLink(params)
+	if err != nil {
+		slog.Error("Error creating invite link", "name", name, "error", err)
+		app.replyText(message, "Не получилось создать ссылку, проверьте, что бот — администратор группы с правом приглашать участников.")
+		return
+	}
+	link.Url = created.InviteLink
+
+	err = app.store.update(func(data *storeData) {
+		data.LastInviteLinkID++
+		link.ID = data.LastInviteLinkID
+		data.InviteLinks = append(data.InviteLinks, &link)
+	})
+	if err != nil {
+		slog.Error("Error saving invite link", "name", name, "url", link.Url, "error", err)
+		app.replyText(message, fmt.Sprintf("Ссылка создана, но не сохранилась, вступления по ней могут не посчитаться. Отзовите её в настройках группы и попробуйте ещё раз: %s", link.Url))
+		return
+	}
+	slog.Info("Invite link created", "id", link.ID, "name", name, "user_id", message.From.ID)
+	app.replyText(message, fmt.Sprintf("Ссылка №%d «%s»: %s\nСписок: /invites, отозвать: /revoke %d", link.ID, link.Name, link.Url, link.ID))
+}
+
+func (app *App) inviteLinks() []InviteLink {
+	var links []InviteLink
+	app.store.view(func(data *storeData) {
+		for _, link := range data.InviteLinks {
+			links = append(links, *link)
+		}
+	})
+	return links
+}
+
+func createInviteLinksList(links []InviteLink, now time.Time, location *time.Location) string {
+	if len(links) == 0 {
+		return "Ссылок-приглашений пока нет. " + inviteUsage
+	}
+	lines := []string{"Ссылки-приглашения:"}
+	for _, link := range links {
+		details := []string{fmt.Sprintf("вступили: %d", link.Joins)}
+		if link.MemberLimit > 0 {
+			details = append(details, fmt.Sprintf("мест: %d", link.MemberLimit))
+		}
+		switch {
+		case link.Revoked:
+			details = append(details, "отозвана")
+		case !link.ExpiresAt.IsZero() && !link.ExpiresAt.After(now):
+			details = append(details, "истекла")
+		case !link.ExpiresAt.IsZero():
+			details = append(details, "до "+formatAnnouncementTime(link.ExpiresAt, location))
+		}
+		lines = append(lines, fmt.Sprintf("№%d %s — %s\n%s", link.ID, link.Name, strings.Join(details, ", "), link.Url))
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+func (app *App) handleInvitesCommand(message *Message, args string) {
+	app.replyText(message, createInviteLinksList(app.inviteLinks(), time.Now(), app.announcementLocation()))
+}
+
+func (app *App) handleRevokeCommand(message *Message, args string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(args, "№"), 10, 64)
+	links := app.inviteLinks()
+	index := slices.IndexFunc(links, func(link InviteLink) bool { return link.ID == id && !link.Revoked })
+	if err != nil || index < 0 {
+		app.replyText(message, "Ссылка не найдена. Список: /invites")
+		return
+	}
+	link := links[index]
+
+	if err := app.telegram.revokeChatInviteLink(app.config.ChatID, link.Url); err != nil {
+		slog.Error("Error revoking invite link", "id", link.ID, "error", err)
+		app.replyText(message, "Не получилось отозвать ссылку.")
+		return
+	}
+	err = app.store.update(func(data *storeData) {
+		for _, stored := range data.InviteLinks {
+			if stored.ID == link.ID {
+				stored.Revoked = true
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving invite link", "id", link.ID, "error", err)
+	}
+	slog.Info("Invite link revoked", "id", link.ID, "user_id", message.From.ID)
+	app.replyText(message, fmt.Sprintf("Ссылка №%d «%s» отозвана, по ней больше нельзя вступить.", link.ID, link.Name))
+}
+
+func isInChat(member *ChatMember) bool {
+	if member.Status == "restricted" {
+		return member.IsMember
+	}
+	return isAdminStatus(member.Status) || member.Status == "member"
+}
+
+func (app *App) isChatMemberJoined(update *ChatMemberUpdated) bool {
+	return update != nil &&
+		update.Chat.ID == app.config.ChatID &&
+		!isInChat(&update.OldChatMember) &&
+		isInChat(&update.NewChatMember)
+}
+
+// handleChatMemberJoined attributes a join to the invite link it came through
+func (app *App) handleChatMemberJoined(update *ChatMemberUpdated) {
+	if update.InviteLink == nil {
+		return
+	}
+	userID := update.NewChatMember.User.ID
+	var linkID int64
+	app.store.view(func(data *storeData) {
+		index := slices.IndexFunc(data.InviteLinks, func(link *InviteLink) bool { return link.Url == update.InviteLink.InviteLink })
+		if index >= 0 {
+			linkID = data.InviteLinks[index].ID
+		}
+	})
+	// Links created in the Telegram app rather than with /invite are only logged
+	slog.Info("Member joined with an invite link", "user_id", userID, "id", linkID, "name", update.InviteLink.Name)
+	if linkID == 0 {
+		return
+	}
+
+	err := app.store.update(func(data *storeData) {
+		for _, link := range data.InviteLinks {
+			if link.ID == linkID {
+				link.Joins++
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving invite link join", "user_id", userID, "id", linkID, "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func inviteLinksTestConfig(config *Config) {
+	config.Announcements = Announcements{Timezone: "Europe/Moscow"}
+}
+
+func setInviteLinkResponse(api *fakeTelegramApi) {
+	api.setResponse("createChatInviteLink", `{"ok": true, "result": {"invite_link": "https://t.me/+AbCdEf", "name": "Ярмарка на Тверской"}}`)
+}
+
+func createTestAdminCommand(text string) *Update {
+	return &Update{Message: &Message{Text: text, Chat: Chat{ID: 555, Type: "supergroup"}, From: User{ID: 777, FirstName: "Admin"}}}
+}
+
+func createTestChatMemberJoin(inviteLink string, oldStatus string) *Update {
+	update := &ChatMemberUpdated{
+		Chat:          Chat{ID: 123456789, Type: "supergroup"},
+		From:          testHuman,
+		OldChatMember: ChatMember{Status: oldStatus, User: testHuman},
+		NewChatMember: ChatMember{Status: "member", User: testHuman},
+	}
+	if inviteLink != "" {
+		update.InviteLink = &ChatInviteLink{InviteLink: inviteLink}
+	}
+	return &Update{ChatMember: update}
+}
+
+func TestParseInviteArgs(t *testing.T) {
+	tests := []struct {
+		args          string
+		expectedName  string
+		expectedDays  int
+		expectedLimit int
+		expectedOk    bool
+	}{
+		{args: "Ярмарка на Тверской дней=7 мест=100", expectedName: "Ярмарка на Тверской", expectedDays: 7, expectedLimit: 100, expectedOk: true},
+		{args: "Instagram stories дней=30", expectedName: "Instagram stories", expectedDays: 30, expectedOk: true},
+		{args: "мест=5 Визитки", expectedName: "Визитки", expectedLimit: 5, expectedOk: true},
+		{args: "Ярмарка 2026", expectedName: "Ярмарка 2026", expectedOk: true},
+		{args: "Ярмарка", expectedName: "Ярмарка", expectedOk: true},
+		{args: "", expectedOk: false},
+		{args: "дней=7", expectedOk: false},
+		{args: "Ярмарка дней=-1", expectedOk: false},
+		{args: "Ярмарка дней=неделя", expectedOk: false},
+		{args: "Ярмарка мест=100000", expectedOk: false},
+		{args: strings.Repeat("я", 33), expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			name, days, limit, ok := parseInviteArgs(tt.args)
+			if name != tt.expectedName || days != tt.expectedDays || limit != tt.expectedLimit || ok != tt.expectedOk {
+				t.Errorf("Expected (%q, %d, %d, %v), got (%q, %d, %d, %v)",
+					tt.expectedName, tt.expectedDays, tt.expectedLimit, tt.expectedOk, name, days, limit, ok)
+			}
+		})
+	}
+}
+
+func TestInviteLinks(t *testing.T) {
+	app, api := newTestApp(t, inviteLinksTestConfig)
+	setInviteLinkResponse(api)
+
+	app.handleTelegramUpdate(createTestAdminCommand("/invite Ярмарка на Тверской дней=7 мест=100"))
+
+	calls := api.callsTo("createChatInviteLink")
+	if len(calls) != 1 {
+		t.Fatalf("Expected an invite link to be created, got %d calls", len(calls))
+	}
+	params := calls[0].Params
+	expireDate := time.Unix(int64(params["expire_date"].(float64)), 0)
+	if params["chat_id"] != float64(123456789) || params["name"] != "Ярмарка на Тверской" || params["member_limit"] != float64(100) ||
+		expireDate.Sub(time.Now().AddDate(0, 0, 7)).Abs() > time.Minute {
+		t.Errorf("Expected a link to the group for 7 days and 100 members, got %v", params)
+	}
+	if text := lastSentText(t, api); !strings.Contains(text, "Ссылка №1 «Ярмарка на Тверской»: https://t.me/+AbCdEf") {
+		t.Errorf("Expected the link in the reply, got %q", text)
+	}
+
+	app.handleTelegramUpdate(createTestChatMemberJoin("https://t.me/+AbCdEf", "left"))
+	app.handleTelegramUpdate(createTestChatMemberJoin("https://t.me/+other", "left"))
+	app.handleTelegramUpdate(createTestChatMemberJoin("https://t.me/+AbCdEf", "member"))
+
+	app.handleTelegramUpdate(createTestAdminCommand("/invites"))
+	if text := lastSentText(t, api); !strings.Contains(text, "№1 Ярмарка на Тверской — вступили: 1, мест: 100, до ") {
+		t.Errorf("Expected 1 join in the list, got %q", text)
+	}
+
+	app.handleTelegramUpdate(createTestAdminCommand("/revoke 1"))
+	revokes := api.callsTo("revokeChatInviteLink")
+	if len(revokes) != 1 || revokes[0].Params["invite_link"] != "https://t.me/+AbCdEf" {
+		t.Errorf("Expected the link to be revoked, got %v", revokes)
+	}
+	app.handleTelegramUpdate(createTestAdminCommand("/revoke 1"))
+	if text := lastSentText(t, api); !strings.Contains(text, "не найдена") {
+		t.Errorf("Expected a revoked link not to be revoked again, got %q", text)
+	}
+	if links := app.inviteLinks(); len(links) != 1 || !links[0].Revoked {
+		t.Errorf("Expected a revoked link, got %+v", links)
+	}
+}
+
+func TestInviteCommandOnlyInAdminChat(t *testing.T) {
+	app, api := newTestApp(t, inviteLinksTestConfig)
+	setInviteLinkResponse(api)
+
+	app.handleTelegramUpdate(createTestCommand("/invite Ярмарка"))
+
+	if calls := api.recordedCalls(); len(calls) != 0 {
+		t.Errorf("Expected /invite to be ignored outside the admin chat, got %v", calls)
+	}
+}
+
+func TestCreateInviteLinksList(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	links := []InviteLink{
+		{ID: 1, Name: "Ярмарка", Url: "https://t.me/+a", Joins: 12, ExpiresAt: now.Add(-time.Hour)},
+		{ID: 2, Name: "Instagram", Url: "https://t.me/+b", Joins: 3, Revoked: true},
+		{ID: 3, Name: "Визитки", Url: "https://t.me/+c"},
+	}
+
+	expected := "Ссылки-приглашения:\n\n" +
+		"№1 Ярмарка — вступили: 12, истекла\nhttps://t.me/+a\n\n" +
+		"№2 Instagram — вступили: 3, отозвана\nhttps://t.me/+b\n\n" +
+		"№3 Визитки — вступили: 0\nhttps://t.me/+c"
+	if list := createInviteLinksList(links, now, time.UTC); list != expected {
+		t.Errorf("Expected %q, got %q", expected, list)
+	}
+}
+
+func TestInviteLinkSaveFailure(t *testing.T) {
+	app, api := newTestApp(t, inviteLinksTestConfig)
+	setInviteLinkResponse(api)
+	app.store.path = "/dev/null/store.json"
+
+	app.handleTelegramUpdate(createTestAdminCommand("/invite Ярмарка"))
+
+	if text := lastSentText(t, api); !strings.HasPrefix(text, "Ссылка создана, но не сохранилась") || !strings.Contains(text, "https://t.me/+AbCdEf") {
+		t.Errorf("Expected the failure to be reported with the link, got %q", text)
+	}
+}
+
+func TestChatMemberJoinWithForeignLink(t *testing.T) {
+	app, _ := newTestApp(t, inviteLinksTestConfig)
+	written := watchStoreWrites(t, app.store)
+
+	app.handleTelegramUpdate(createTestChatMemberJoin("https://t.me/+other", "left"))
+
+	if written() {
+		t.Error("Expected a join with a link that is not ours to leave the store alone")
+	}
+}
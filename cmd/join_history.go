@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	returningModeShort = "short"
+	returningModeSkip  = "skip"
+
+	defaultReturningTemplate = "С возвращением, {mentions}!"
+)
+
+func isReturningMember(record *MemberRecord, cooldown time.Duration, now time.Time) bool {
+	return record != nil &&
+		cooldown > 0 &&
+		now.Sub(record.LastJoinedAt) < cooldown
+}
+
+// recordJoins stores the join time of every member and returns those who
+// already joined within the configured cooldown.
+func (app *App) recordJoins(members []User, now time.Time) (newcomers []User, returning []User) {
+	cooldown := app.config.ReturningMembers.Cooldown
+	err := app.store.update(func(data *storeData) {
+		for _, member := range members {
+			record := data.Members[member.ID]
+			if isReturningMember(record, cooldown, now) {
+				returning = append(returning, member)
+			} else {
+				newcomers = append(newcomers, member)
+			}
+
+			if record == nil {
+				record = &MemberRecord{FirstJoinedAt: now}
+				data.Members[member.ID] = record
+			}
+			record.LastJoinedAt = now
+			record.Joins++
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving join history", "error", err)
+	}
+	return newcomers, returning
+}
+
+func createReturningMessage(template string, members []User) string {
+	if template == "" {
+		template = defaultReturningTemplate
+	}
+	return renderMentionsTemplate(template, members)
+}
+
+func (app *App) sendReturningMembersMessage(members []User) {
+	settings := &app.config.ReturningMembers
+	if settings.Mode == returningModeSkip {
+		slog.Info("Skipping welcome for returning members", "count", len(members))
+		return
+	}
+
+	payload := map[string]any{
+		"chat_id":    app.config.ChatID,
+		"text":       createReturningMessage(settings.Template, members),
+		"parse_mode": "HTML",
+	}
+	// Returning members are greeted once, in the first welcome topic
+	setMessageThreadID(payload, app.welcomeTopics()[0].ID)
+	if _, err := app.telegram.sendMessage(payload); err != nil {
+		slog.Error("Error sending message", "error", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsReturningMember(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		record   *MemberRecord
+		cooldown time.Duration
+		expected bool
+	}{
+		{
+			name:     "first join",
+			record:   nil,
+			cooldown: 24 * time.Hour,
+			expected: false,
+		},
+		{
+			name:     "rejoined within cooldown",
+			record:   &MemberRecord{LastJoinedAt: now.Add(-time.Hour)},
+			cooldown: 24 * time.Hour,
+			expected: true,
+		},
+		{
+			name:     "rejoined after cooldown",
+			record:   &MemberRecord{LastJoinedAt: now.Add(-48 * time.Hour)},
+			cooldown: 24 * time.Hour,
+			expected: false,
+		},
+		{
+			name:     "cooldown disabled",
+			record:   &MemberRecord{LastJoinedAt: now.Add(-time.Hour)},
+			cooldown: 0,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isReturningMember(tt.record, tt.cooldown, now)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCreateReturningMessage(t *testing.T) {
+	members := []User{
+		{ID: 111222333, FirstName: "Jane", Username: "janesmith"},
+		{ID: 444555666, FirstName: "Bob", Username: "bobjohnson"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "default template",
+			template: "",
+			expected: "С возвращением, @janesmith и @bobjohnson!",
+		},
+		{
+			name:     "custom template",
+			template: "{mentions}, рады снова видеть!",
+			expected: "@janesmith и @bobjohnson, рады снова видеть!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := createReturningMessage(tt.template, members)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func returningTestConfig(settings ReturningMembers) func(config *Config) {
+	return func(config *Config) {
+		config.ReturningMembers = settings
+	}
+}
+
+func TestRecordJoins(t *testing.T) {
+	app, _ := newTestApp(t, returningTestConfig(ReturningMembers{Cooldown: 24 * time.Hour}))
+	jane := User{ID: 111222333, FirstName: "Jane"}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	newcomers, returning := app.recordJoins([]User{jane}, now)
+	if len(newcomers) != 1 || len(returning) != 0 {
+		t.Errorf("Expected first join to be a newcomer, got %d newcomers and %d returning", len(newcomers), len(returning))
+	}
+
+	newcomers, returning = app.recordJoins([]User{jane}, now.Add(time.Hour))
+	if len(newcomers) != 0 || len(returning) != 1 {
+		t.Errorf("Expected rejoin to be returning, got %d newcomers and %d returning", len(newcomers), len(returning))
+	}
+
+	app.store.view(func(data *storeData) {
+		record := data.Members[jane.ID]
+		if record.Joins != 2 {
+			t.Errorf("Expected 2 joins, got %d", record.Joins)
+		}
+		if !record.FirstJoinedAt.Equal(now) {
+			t.Errorf("Expected first join at %v, got %v", now, record.FirstJoinedAt)
+		}
+	})
+}
+
+func TestReturningMemberWelcome(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		expectedCalls int
+	}{
+		{
+			name:          "short welcome",
+			mode:          returningModeShort,
+			expectedCalls: 2,
+		},
+		{
+			name:          "skip welcome",
+			mode:          returningModeSkip,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, api := newTestApp(t, returningTestConfig(ReturningMembers{Cooldown: 24 * time.Hour, Mode: tt.mode}))
+			update := createTestMembersJoin(User{ID: 111222333, FirstName: "Jane", Username: "janesmith"})
+
+			app.handleTelegramUpdate(update)
+			app.handleTelegramUpdate(update)
+
+			calls := api.callsTo("sendMessage")
+			if len(calls) != tt.expectedCalls {
+				t.Fatalf("Expected %d messages, got %d", tt.expectedCalls, len(calls))
+			}
+			if tt.mode == returningModeShort && !strings.HasPrefix(calls[1].Params["text"].(string), "С возвращением") {
+				t.Errorf("Expected short welcome, got %s", calls[1].Params["text"])
+			}
+		})
+	}
+}
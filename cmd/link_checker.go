@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LinkChecker struct {
+	httpClient *http.Client
+	threshold  int
+	alert      func(text string)
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func newLinkChecker(timeout time.Duration, threshold int, alert func(text string)) *LinkChecker {
+	return &LinkChecker{
+		httpClient: &http.Client{Timeout: timeout},
+		threshold:  threshold,
+		alert:      alert,
+		failures:   make(map[string]int),
+	}
+}
+
+func (c *LinkChecker) request(ctx context.Context, method string, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// checkUrl tries a HEAD request first and falls back to GET for servers that don't support it
+func (c *LinkChecker) checkUrl(ctx context.Context, url string) error {
+	status, err := c.request(ctx, http.MethodHead, url)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.request(ctx, http.MethodGet, url)
+	}
+	if err != nil {
+		return err
+	}
+	if status >= http.StatusBadRequest {
+		return fmt.Errorf("status %d %s", status, http.StatusText(status))
+	}
+	return nil
+}
+
+// recordResult updates the failure streak of url and reports whether admins should hear about it:
+// once when the streak reaches the threshold and once when the link recovers after that.
+func (c *LinkChecker) recordResult(url string, err error) (alert bool, recovered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		recovered = c.failures[url] >= c.threshold
+		delete(c.failures, url)
+		return false, recovered
+	}
+	c.failures[url]++
+	return c.failures[url] == c.threshold, false
+}
+
+func groupButtonsByUrl(buttons []Button) (urls []string, names map[string][]string) {
+	names = make(map[string][]string)
+	for _, button := range buttons {
+		if _, ok := names[button.Url]; !ok {
+			urls = append(urls, button.Url)
+		}
+		names[button.Url] = append(names[button.Url], "«"+button.Text+"»")
+	}
+	return urls, names
+}
+
+func (c *LinkChecker) checkAll(ctx context.Context, buttons []Button) {
+	urls, names := groupButtonsByUrl(buttons)
+	for _, url := range urls {
+		err := c.checkUrl(ctx, url)
+		if err != nil {
+			slog.Warn("Link check failed", "url", url, "error", err)
+		}
+
+		alert, recovered := c.recordResult(url, err)
+		buttonNames := strings.Join(names[url], ", ")
+		switch {
+		case alert:
+			c.alert(fmt.Sprintf("Ссылка не открывается %d раз подряд: %s\nКнопки: %s\nОшибка: %s", c.threshold, url, buttonNames, err))
+		case recovered:
+			c.alert(fmt.Sprintf("Ссылка снова открывается: %s\nКнопки: %s", url, buttonNames))
+		}
+	}
+}
+
+func (c *LinkChecker) run(ctx context.Context, interval time.Duration, buttons func() []Button) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.checkAll(ctx, buttons())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *App) alertAdmins(text string) {
+	if app.config.AdminChatID == 0 {
+		slog.Warn("ADMIN_CHAT_ID is not set, alert is only logged", "text", text)
+		return
+	}
+	_, err := app.telegram.sendMessage(map[string]any{
+		"chat_id": app.config.AdminChatID,
+		"text":    text,
+	})
+	if err != nil {
+		slog.Error("Error sending alert to admins", "error", err)
+	}
+}
+
+func (app *App) startLinkChecker(ctx context.Context) {
+	settings := &app.config.LinkCheck
+	if !settings.Enabled {
+		return
+	}
+	checker := newLinkChecker(settings.Timeout, settings.Failures, app.alertAdmins)
+	// Every button is checked, tracked or not
+	go checker.run(ctx, settings.Interval, app.allButtons)
+}
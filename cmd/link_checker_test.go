@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedAlerts struct {
+	mu     sync.Mutex
+	alerts []string
+}
+
+func (r *recordedAlerts) alert(text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, text)
+}
+
+func (r *recordedAlerts) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.alerts...)
+}
+
+func newTestLinkServer(t *testing.T) (*httptest.Server, *sync.Map) {
+	statuses := &sync.Map{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/head-not-allowed" && r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		status, ok := statuses.Load(r.URL.Path)
+		if !ok {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status.(int))
+	}))
+	t.Cleanup(server.Close)
+	return server, statuses
+}
+
+func TestLinkCheckerCheckUrl(t *testing.T) {
+	server, statuses := newTestLinkServer(t)
+	statuses.Store("/missing", http.StatusNotFound)
+	checker := newLinkChecker(time.Second, 3, func(text string) {})
+
+	tests := []struct {
+		name        string
+		url         string
+		expectError bool
+	}{
+		{name: "ok", url: server.URL + "/ok", expectError: false},
+		{name: "not found", url: server.URL + "/missing", expectError: true},
+		{name: "head not allowed falls back to get", url: server.URL + "/head-not-allowed", expectError: false},
+		{name: "unreachable", url: "http://127.0.0.1:1/down", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checker.checkUrl(context.Background(), tt.url)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestLinkCheckerAlertsAfterThreshold(t *testing.T) {
+	server, statuses := newTestLinkServer(t)
+	statuses.Store("/soap", http.StatusNotFound)
+	alerts := &recordedAlerts{}
+	checker := newLinkChecker(time.Second, 3, alerts.alert)
+	buttons := []Button{
+		{Text: "Что такое крафтовое мыло", Url: server.URL + "/soap"},
+		{Text: "Мыло", Url: server.URL + "/soap"},
+		{Text: "Что такое убтан", Url: server.URL + "/ubtan"},
+	}
+
+	for range 2 {
+		checker.checkAll(context.Background(), buttons)
+	}
+	if len(alerts.all()) != 0 {
+		t.Fatalf("Expected no alerts before the threshold, got %v", alerts.all())
+	}
+
+	checker.checkAll(context.Background(), buttons)
+	checker.checkAll(context.Background(), buttons)
+	if len(alerts.all()) != 1 {
+		t.Fatalf("Expected exactly one alert, got %v", alerts.all())
+	}
+	alert := alerts.all()[0]
+	for _, expected := range []string{"/soap", "«Что такое крафтовое мыло», «Мыло»", "404"} {
+		if !strings.Contains(alert, expected) {
+			t.Errorf("Expected alert to contain %q, got %s", expected, alert)
+		}
+	}
+
+	statuses.Store("/soap", http.StatusOK)
+	checker.checkAll(context.Background(), buttons)
+	if len(alerts.all()) != 2 || !strings.Contains(alerts.all()[1], "снова открывается") {
+		t.Errorf("Expected recovery alert, got %v", alerts.all())
+	}
+}
+
+func TestLinkCheckerResetsStreakOnSuccess(t *testing.T) {
+	checker := newLinkChecker(time.Second, 2, func(text string) {})
+	failure := context.DeadlineExceeded
+
+	checker.recordResult("https://example.com", failure)
+	alert, recovered := checker.recordResult("https://example.com", nil)
+	if alert || recovered {
+		t.Error("Expected no alert or recovery before the threshold")
+	}
+	if alert, _ := checker.recordResult("https://example.com", failure); alert {
+		t.Error("Expected streak to restart after a success")
+	}
+}
+
+func TestAlertAdmins(t *testing.T) {
+	app, api := newTestApp(t, func(config *Config) {
+		config.AdminChatID = 0
+	})
+
+	app.alertAdmins("без админского чата")
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("Expected no message without ADMIN_CHAT_ID")
+	}
+
+	app.config.AdminChatID = 555
+	app.alertAdmins("ссылка сломалась")
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["chat_id"] != float64(555) {
+		t.Errorf("Expected alert in the admin chat, got %+v", calls)
+	}
+}
+
+func TestLinkCheckerChecksUntrackedButtons(t *testing.T) {
+	app, _ := newTestApp(t, nil)
+	app.config.Welcome.Topics = []WelcomeTopic{{ID: 5, Buttons: []Button{{Text: "Карта", Url: "https://example.com/map"}}}}
+	app.config.Onboarding.Steps = []OnboardingStep{{Buttons: []Button{{Text: "Читать", Url: "https://example.com/skin"}}}}
+
+	urls, _ := groupButtonsByUrl(app.allButtons())
+	for _, expected := range []string{"https://example.com/prices", "https://example.com/map", "https://example.com/skin"} {
+		if !slices.Contains(urls, expected) {
+			t.Errorf("Expected %s to be checked, got %v", expected, urls)
+		}
+	}
+	if buttons := app.configuredButtons(); len(buttons) != 4 {
+		t.Errorf("Expected only the link buttons to be tracked, got %v", buttons)
+	}
+}
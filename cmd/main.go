@@ -1,10 +1,13 @@
 package main
 
+import (
+	"log/slog"
+	"os"
+)
+
 func main() {
-	config := newConfig()
-	app := &App{
-		config: config,
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		slog.Error("Command failed", "error", err)
+		os.Exit(1)
 	}
-	app.registerRoutes()
-	app.startServer()
 }
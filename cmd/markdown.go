@@ -0,0 +1,108 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NodeElement is a Telegraph DOM element, its children are strings or *NodeElement
+type NodeElement struct {
+	Tag      string            `json:"tag"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Children []any             `json:"children,omitempty"`
+}
+
+var (
+	orderedItemPattern = regexp.MustCompile(`^\d+[.)]\s+`)
+	inlinePattern      = regexp.MustCompile(`\*\*(.+?)\*\*|\[([^\]]+)\]\(([^)\s]+)\)|\*(.+?)\*|_(.+?)_`)
+)
+
+// parseInline converts **bold**, *italic*, _italic_ and [links](url) into Telegraph nodes
+func parseInline(text string) []any {
+	var nodes []any
+	last := 0
+	for _, match := range inlinePattern.FindAllStringSubmatchIndex(text, -1) {
+		if match[0] > last {
+			nodes = append(nodes, text[last:match[0]])
+		}
+		group := func(i int) string { return text[match[2*i]:match[2*i+1]] }
+		switch {
+		case match[2] >= 0:
+			nodes = append(nodes, &NodeElement{Tag: "strong", Children: parseInline(group(1))})
+		case match[4] >= 0:
+			nodes = append(nodes, &NodeElement{Tag: "a", Attrs: map[string]string{"href": group(3)}, Children: parseInline(group(2))})
+		case match[8] >= 0:
+			nodes = append(nodes, &NodeElement{Tag: "em", Children: parseInline(group(4))})
+		default:
+			nodes = append(nodes, &NodeElement{Tag: "em", Children: parseInline(group(5))})
+		}
+		last = match[1]
+	}
+	if last < len(text) {
+		nodes = append(nodes, text[last:])
+	}
+	return nodes
+}
+
+// markdownToTelegraph converts the Markdown subset used in content/*.md into Telegraph nodes.
+// The first "# " heading becomes the page title.
+func markdownToTelegraph(source string) (title string, nodes []any) {
+	var paragraph []string
+	var list *NodeElement
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			nodes = append(nodes, &NodeElement{Tag: "p", Children: parseInline(strings.Join(paragraph, " "))})
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if list != nil {
+			nodes = append(nodes, list)
+			list = nil
+		}
+	}
+	addListItem := func(tag string, text string) {
+		flushParagraph()
+		if list == nil || list.Tag != tag {
+			flushList()
+			list = &NodeElement{Tag: tag}
+		}
+		list.Children = append(list.Children, &NodeElement{Tag: "li", Children: parseInline(text)})
+	}
+	addBlock := func(tag string, text string) {
+		flushParagraph()
+		flushList()
+		nodes = append(nodes, &NodeElement{Tag: tag, Children: parseInline(text)})
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			flushParagraph()
+			flushList()
+		case strings.HasPrefix(line, "# ") && title == "":
+			flushParagraph()
+			flushList()
+			title = strings.TrimSpace(line[2:])
+		case strings.HasPrefix(line, "### "):
+			addBlock("h4", strings.TrimSpace(line[4:]))
+		case strings.HasPrefix(line, "## "), strings.HasPrefix(line, "# "):
+			_, text, _ := strings.Cut(line, " ")
+			addBlock("h3", strings.TrimSpace(text))
+		case strings.HasPrefix(line, "> "):
+			addBlock("blockquote", strings.TrimSpace(line[2:]))
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			addListItem("ul", strings.TrimSpace(line[2:]))
+		case orderedItemPattern.MatchString(line):
+			addListItem("ol", orderedItemPattern.ReplaceAllString(line, ""))
+		default:
+			flushList()
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushParagraph()
+	flushList()
+	return title, nodes
+}
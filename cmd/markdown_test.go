@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func nodesJson(t *testing.T, nodes []any) string {
+	t.Helper()
+	content, err := json.Marshal(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "plain text",
+			text:     "Мыло ручной работы",
+			expected: `["Мыло ручной работы"]`,
+		},
+		{
+			name:     "bold",
+			text:     "Только **натуральные** масла",
+			expected: `["Только ",{"tag":"strong","children":["натуральные"]}," масла"]`,
+		},
+		{
+			name:     "italic",
+			text:     "*холодный* и _горячий_ способ",
+			expected: `[{"tag":"em","children":["холодный"]}," и ",{"tag":"em","children":["горячий"]}," способ"]`,
+		},
+		{
+			name:     "link",
+			text:     "Смотрите [прайс](https://example.com/prices).",
+			expected: `["Смотрите ",{"tag":"a","attrs":{"href":"https://example.com/prices"},"children":["прайс"]},"."]`,
+		},
+		{
+			name:     "bold link",
+			text:     "[**заказ**](https://example.com)",
+			expected: `[{"tag":"a","attrs":{"href":"https://example.com"},"children":[{"tag":"strong","children":["заказ"]}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := nodesJson(t, parseInline(tt.text))
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMarkdownToTelegraph(t *testing.T) {
+	source := "# Что такое убтан\r\n" +
+		"\n" +
+		"Убтан — это смесь\n" +
+		"трав и муки.\n" +
+		"\n" +
+		"## Состав\n" +
+		"- нутовая мука\n" +
+		"- куркума\n" +
+		"\n" +
+		"### Как использовать\n" +
+		"1. Смешать с водой\n" +
+		"2. Нанести на кожу\n" +
+		"> Не для ежедневного применения\n"
+
+	title, nodes := markdownToTelegraph(source)
+
+	if title != "Что такое убтан" {
+		t.Errorf("Expected title %q, got %q", "Что такое убтан", title)
+	}
+	expected := `[` +
+		`{"tag":"p","children":["Убтан — это смесь трав и муки."]},` +
+		`{"tag":"h3","children":["Состав"]},` +
+		`{"tag":"ul","children":[{"tag":"li","children":["нутовая мука"]},{"tag":"li","children":["куркума"]}]},` +
+		`{"tag":"h4","children":["Как использовать"]},` +
+		`{"tag":"ol","children":[{"tag":"li","children":["Смешать с водой"]},{"tag":"li","children":["Нанести на кожу"]}]},` +
+		`{"tag":"blockquote","children":["Не для ежедневного применения"]}` +
+		`]`
+	if result := nodesJson(t, nodes); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
+
+func TestMarkdownToTelegraphWithoutTitle(t *testing.T) {
+	title, nodes := markdownToTelegraph("Просто текст")
+	if title != "" {
+		t.Errorf("Expected no title, got %q", title)
+	}
+	if len(nodes) != 1 {
+		t.Errorf("Expected 1 node, got %d", len(nodes))
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Counter is a Prometheus-style counter with a single label
+type Counter struct {
+	name   string
+	help   string
+	label  string
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func newCounter(name string, help string, label string) *Counter {
+	return &Counter{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]int64),
+	}
+}
+
+func (c *Counter) inc(labelValue string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelValue]++
+}
+
+func (c *Counter) value(labelValue string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *Counter) writeTo(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	labelValues := make([]string, 0, len(c.values))
+	for labelValue := range c.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	slices.Sort(labelValues)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", c.name, c.label, strconv.Quote(labelValue), c.values[labelValue])
+	}
+}
+
+type Metrics struct {
+	updates      *Counter
+	buttonClicks *Counter
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		updates:      newCounter("bot_updates_total", "Telegram updates received by kind.", "kind"),
+		buttonClicks: newCounter("bot_button_clicks_total", "Tracked welcome button clicks.", "button"),
+	}
+}
+
+func (m *Metrics) counters() []*Counter {
+	return []*Counter{m.updates, m.buttonClicks}
+}
+
+// metricsHandler serves the counters to requests with METRICS_TOKEN as a bearer token,
+// the endpoint is off when the token is not set
+func (app *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	token := app.config.MetricsToken
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, counter := range app.metrics.counters() {
+		counter.writeTo(w)
+	}
+}
+
+func (app *App) metricsMiddleware(next UpdateHandler) UpdateHandler {
+	return UpdateHandlerFunc(func(update *Update) {
+		app.metrics.updates.inc(update.kind())
+		next.HandleUpdate(update)
+	})
+}
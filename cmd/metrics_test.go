@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterWriteTo(t *testing.T) {
+	counter := newCounter("bot_test_total", "Test counter.", "kind")
+	counter.inc("message")
+	counter.inc("message")
+	counter.inc("callback_query")
+
+	var output strings.Builder
+	counter.writeTo(&output)
+
+	expected := `# HELP bot_test_total Test counter.
+# TYPE bot_test_total counter
+bot_test_total{kind="callback_query"} 1
+bot_test_total{kind="message"} 2
+`
+	if output.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, output.String())
+	}
+}
+
+func TestMetricsMiddlewareAndHandler(t *testing.T) {
+	app := &App{config: &Config{MetricsToken: "secret"}, metrics: newMetrics()}
+	handler := app.metricsMiddleware(UpdateHandlerFunc(func(update *Update) {}))
+
+	handler.HandleUpdate(&Update{Message: &Message{}})
+	handler.HandleUpdate(&Update{})
+
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/metrics", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	app.metricsHandler(rr, request)
+
+	body := rr.Body.String()
+	for _, expected := range []string{`bot_updates_total{kind="message"} 1`, `bot_updates_total{kind="unknown"} 1`, "bot_button_clicks_total"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q, got %s", expected, body)
+		}
+	}
+}
+
+func TestMetricsHandlerRequiresToken(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "no token configured", token: "", authorization: "Bearer ", expectedStatus: http.StatusNotFound},
+		{name: "missing token", token: "secret", authorization: "", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{config: &Config{MetricsToken: tt.token}, metrics: newMetrics()}
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+
+			app.metricsHandler(rr, request)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type UpdateHandler interface {
+	HandleUpdate(update *Update)
+}
+
+type UpdateHandlerFunc func(update *Update)
+
+func (f UpdateHandlerFunc) HandleUpdate(update *Update) {
+	f(update)
+}
+
+type Middleware func(next UpdateHandler) UpdateHandler
+
+// chainMiddleware wraps handler so that the first middleware runs first
+func chainMiddleware(handler UpdateHandler, middlewares ...Middleware) UpdateHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// use registers middlewares for the update handler, it must be called before serving
+func (app *App) use(middlewares ...Middleware) {
+	app.middlewares = append(app.middlewares, middlewares...)
+}
+
+func (app *App) updateHandler() UpdateHandler {
+	app.handlerOnce.Do(func() {
+		app.handler = chainMiddleware(UpdateHandlerFunc(app.handleTelegramUpdate), app.middlewares...)
+	})
+	return app.handler
+}
+
+func recoverMiddleware(next UpdateHandler) UpdateHandler {
+	return UpdateHandlerFunc(func(update *Update) {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("Recovered from panic in update handler",
+					"update_id", update.UpdateID,
+					"panic", r,
+					"stack", string(debug.Stack()))
+			}
+		}()
+		next.HandleUpdate(update)
+	})
+}
+
+func loggingMiddleware(next UpdateHandler) UpdateHandler {
+	return UpdateHandlerFunc(func(update *Update) {
+		start := time.Now()
+		next.HandleUpdate(update)
+		slog.Info("Handled update",
+			"update_id", update.UpdateID,
+			"kind", update.kind(),
+			"chat_id", update.chatID(),
+			"duration", time.Since(start))
+	})
+}
+
+// newDedupMiddleware skips updates Telegram redelivers after a slow or failed webhook response.
+// It remembers the last size update IDs.
+func newDedupMiddleware(size int) Middleware {
+	var mu sync.Mutex
+	seen := make(map[int64]bool, size)
+	order := make([]int64, 0, size)
+
+	return func(next UpdateHandler) UpdateHandler {
+		return UpdateHandlerFunc(func(update *Update) {
+			if update.UpdateID != 0 {
+				mu.Lock()
+				duplicate := seen[update.UpdateID]
+				if !duplicate {
+					if len(order) == size {
+						delete(seen, order[0])
+						order = order[1:]
+					}
+					seen[update.UpdateID] = true
+					order = append(order, update.UpdateID)
+				}
+				mu.Unlock()
+
+				if duplicate {
+					slog.Info("Skipping duplicate update", "update_id", update.UpdateID)
+					return
+				}
+			}
+			next.HandleUpdate(update)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return UpdateHandlerFunc(func(update *Update) {
+			*calls = append(*calls, name)
+			next.HandleUpdate(update)
+		})
+	}
+}
+
+func TestChainMiddlewareOrder(t *testing.T) {
+	var calls []string
+	handler := chainMiddleware(
+		UpdateHandlerFunc(func(update *Update) {
+			calls = append(calls, "handler")
+		}),
+		recordingMiddleware("first", &calls),
+		recordingMiddleware("second", &calls),
+	)
+
+	handler.HandleUpdate(&Update{})
+
+	expected := []string{"first", "second", "handler"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, calls)
+		}
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(UpdateHandlerFunc(func(update *Update) {
+		panic("malformed update")
+	}))
+
+	// Must not panic
+	handler.HandleUpdate(&Update{UpdateID: 1})
+}
+
+func TestDedupMiddleware(t *testing.T) {
+	handled := 0
+	handler := newDedupMiddleware(2)(UpdateHandlerFunc(func(update *Update) {
+		handled++
+	}))
+
+	tests := []struct {
+		name     string
+		updateID int64
+		expected int
+	}{
+		{name: "first update", updateID: 1, expected: 1},
+		{name: "redelivered update", updateID: 1, expected: 1},
+		{name: "second update", updateID: 2, expected: 2},
+		{name: "third update evicts the first", updateID: 3, expected: 3},
+		{name: "first update after eviction", updateID: 1, expected: 4},
+		{name: "update without id", updateID: 0, expected: 5},
+		{name: "another update without id", updateID: 0, expected: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.HandleUpdate(&Update{UpdateID: tt.updateID})
+			if handled != tt.expected {
+				t.Errorf("Expected %d handled updates, got %d", tt.expected, handled)
+			}
+		})
+	}
+}
+
+func TestAppUseMiddleware(t *testing.T) {
+	var calls []string
+	app := &App{config: &Config{ChatID: 123456789}}
+	app.use(recoverMiddleware, recordingMiddleware("recorded", &calls), func(next UpdateHandler) UpdateHandler {
+		return UpdateHandlerFunc(func(update *Update) {
+			panic("broken handler")
+		})
+	})
+
+	req, err := http.NewRequest("POST", "/bot", bytes.NewBufferString(`{"update_id": 1}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	app.webhookHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Expected registered middleware to run once, got %d", len(calls))
+	}
+}
+
+func TestUpdateKindAndChatID(t *testing.T) {
+	tests := []struct {
+		name           string
+		update         Update
+		expectedKind   string
+		expectedChatID int64
+	}{
+		{
+			name:           "message",
+			update:         Update{Message: &Message{Chat: Chat{ID: 1}}},
+			expectedKind:   "message",
+			expectedChatID: 1,
+		},
+		{
+			name:           "callback query",
+			update:         Update{CallbackQuery: &CallbackQuery{Message: &Message{Chat: Chat{ID: 2}}}},
+			expectedKind:   "callback_query",
+			expectedChatID: 2,
+		},
+		{
+			name:           "chat join request",
+			update:         Update{ChatJoinRequest: &ChatJoinRequest{Chat: Chat{ID: 3}}},
+			expectedKind:   "chat_join_request",
+			expectedChatID: 3,
+		},
+		{
+			name:           "chat member",
+			update:         Update{ChatMember: &ChatMemberUpdated{Chat: Chat{ID: 4}}},
+			expectedKind:   "chat_member",
+			expectedChatID: 4,
+		},
+		{
+			name:           "empty update",
+			update:         Update{},
+			expectedKind:   "unknown",
+			expectedChatID: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kind := tt.update.kind(); kind != tt.expectedKind {
+				t.Errorf("Expected kind %s, got %s", tt.expectedKind, kind)
+			}
+			if chatID := tt.update.chatID(); chatID != tt.expectedChatID {
+				t.Errorf("Expected chat id %d, got %d", tt.expectedChatID, chatID)
+			}
+		})
+	}
+}
@@ -1,28 +1,167 @@
 package main
 
+import "sync"
+
 type App struct {
-	config *Config
+	config    *Config
+	store     *Store
+	telegram  *TelegramClient
+	commands  *CommandRouter
+	callbacks *CallbackRouter
+	metrics   *Metrics
+	// broadcastLimiter is shared by the broadcasts running at the same time
+	broadcastLimiter *rateLimiter
+	clicks           *clickBuffer
+	faq              *FaqResponder
+	schedule         []scheduledJob
+	middlewares      []Middleware
+	handler          UpdateHandler
+	handlerOnce      sync.Once
+}
+
+func newApp(config *Config, store *Store) *App {
+	app := &App{
+		config:           config,
+		store:            store,
+		telegram:         newTelegramClient(config.ApiUrl, config.Token),
+		commands:         newCommandRouter(config.BotUsername),
+		callbacks:        newCallbackRouter(config.Token),
+		metrics:          newMetrics(),
+		broadcastLimiter: newRateLimiter(config.Subscriptions.Rate),
+		clicks:           newClickBuffer(),
+		faq:              newFaqResponder(config.Faq.Entries),
+		schedule:         newScheduledJobs(config.Schedule),
+	}
+	app.registerCommands()
+	app.registerCallbacks()
+	return app
 }
 
 type Update struct {
-	Message *Message `json:"message"`
+	UpdateID        int64              `json:"update_id"`
+	Message         *Message           `json:"message"`
+	CallbackQuery   *CallbackQuery     `json:"callback_query,omitempty"`
+	ChatJoinRequest *ChatJoinRequest   `json:"chat_join_request,omitempty"`
+	InlineQuery     *InlineQuery       `json:"inline_query,omitempty"`
+	ChatMember      *ChatMemberUpdated `json:"chat_member,omitempty"`
+}
+
+func (u *Update) kind() string {
+	switch {
+	case u.Message != nil:
+		return "message"
+	case u.CallbackQuery != nil:
+		return "callback_query"
+	case u.ChatJoinRequest != nil:
+		return "chat_join_request"
+	case u.InlineQuery != nil:
+		return "inline_query"
+	case u.ChatMember != nil:
+		return "chat_member"
+	default:
+		return "unknown"
+	}
+}
+
+func (u *Update) chatID() int64 {
+	switch {
+	case u.Message != nil:
+		return u.Message.Chat.ID
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil:
+		return u.CallbackQuery.Message.Chat.ID
+	case u.ChatJoinRequest != nil:
+		return u.ChatJoinRequest.Chat.ID
+	case u.ChatMember != nil:
+		return u.ChatMember.Chat.ID
+	default:
+		return 0
+	}
 }
 
 type Message struct {
-	Text            string `json:"text"`
-	Chat            Chat   `json:"chat"`
-	From            User   `json:"from"`
-	MessageThreadID int64  `json:"message_thread_id,omitempty"`
-	NewChatMembers  []User `json:"new_chat_members,omitempty"`
+	MessageID       int64       `json:"message_id"`
+	Text            string      `json:"text"`
+	Caption         string      `json:"caption,omitempty"`
+	Chat            Chat        `json:"chat"`
+	From            User        `json:"from"`
+	MessageThreadID int64       `json:"message_thread_id,omitempty"`
+	IsTopicMessage  bool        `json:"is_topic_message,omitempty"`
+	NewChatMembers  []User      `json:"new_chat_members,omitempty"`
+	Photo           []PhotoSize `json:"photo,omitempty"`
+	Contact         *Contact    `json:"contact,omitempty"`
+	ReplyToMessage  *Message    `json:"reply_to_message,omitempty"`
+}
+
+type Contact struct {
+	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name"`
+	UserID      int64  `json:"user_id,omitempty"`
+}
+
+type PhotoSize struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 type Chat struct {
-	ID int64 `json:"id"`
+	ID   int64  `json:"id"`
+	Type string `json:"type,omitempty"`
 }
 
 type User struct {
 	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot,omitempty"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name,omitempty"`
 	Username  string `json:"username,omitempty"`
 }
+
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    User     `json:"from"`
+	Message *Message `json:"message,omitempty"`
+	Data    string   `json:"data,omitempty"`
+}
+
+type InlineQuery struct {
+	ID     string `json:"id"`
+	From   User   `json:"from"`
+	Query  string `json:"query"`
+	Offset string `json:"offset"`
+}
+
+type ChatJoinRequest struct {
+	Chat       Chat   `json:"chat"`
+	From       User   `json:"from"`
+	UserChatID int64  `json:"user_chat_id"`
+	Date       int64  `json:"date"`
+	Bio        string `json:"bio,omitempty"`
+}
+
+type ChatMember struct {
+	Status string `json:"status"`
+	User   User   `json:"user"`
+	// IsMember tells whether a restricted user is in the chat
+	IsMember bool `json:"is_member,omitempty"`
+}
+
+type ChatInviteLink struct {
+	InviteLink  string `json:"invite_link"`
+	Name        string `json:"name,omitempty"`
+	ExpireDate  int64  `json:"expire_date,omitempty"`
+	MemberLimit int    `json:"member_limit,omitempty"`
+	IsRevoked   bool   `json:"is_revoked"`
+}
+
+// ChatMemberUpdated is sent when a member joins or leaves the group, InviteLink is set
+// when they joined with one
+type ChatMemberUpdated struct {
+	Chat          Chat            `json:"chat"`
+	From          User            `json:"from"`
+	Date          int64           `json:"date"`
+	OldChatMember ChatMember      `json:"old_chat_member"`
+	NewChatMember ChatMember      `json:"new_chat_member"`
+	InviteLink    *ChatInviteLink `json:"invite_link,omitempty"`
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+const (
+	onboardingStatusActive  = "active"
+	onboardingStatusDone    = "done"
+	onboardingStatusStopped = "stopped"
+	onboardingStatusOrdered = "ordered"
+	onboardingStatusBlocked = "blocked"
+)
+
+// OnboardingProgress tracks a user through the [onboarding] steps, counted from StartedAt
+type OnboardingProgress struct {
+	StartedAt time.Time `json:"started_at"`
+	NextStep  int       `json:"next_step"`
+	Status    string    `json:"status"`
+}
+
+func hasOrders(data *storeData, userID int64) bool {
+	return slices.ContainsFunc(data.Orders, func(order *Order) bool { return order.Customer.ID == userID })
+}
+
+// startOnboarding begins the sequence for a user who opened the bot for the first time and reports
+// whether the first step was sent right away. When another flow has already answered the user,
+// replied skips the steps that are due right away.
+func (app *App) startOnboarding(user User, now time.Time, replied bool) bool {
+	if !app.config.Onboarding.Enabled {
+		return false
+	}
+	steps := app.config.Onboarding.Steps
+	var started bool
+	err := app.store.update(func(data *storeData) {
+		if _, ok := data.Onboarding[user.ID]; ok {
+			return
+		}
+		progress := &OnboardingProgress{StartedAt: now, Status: onboardingStatusActive}
+		for replied && progress.NextStep < len(steps) && steps[progress.NextStep].Delay <= 0 {
+			progress.NextStep++
+		}
+		if progress.NextStep == len(steps) {
+			progress.Status = onboardingStatusDone
+		}
+		data.Onboarding[user.ID] = progress
+		started = true
+	})
+	if err != nil {
+		slog.Error("Error saving onboarding", "user_id", user.ID, "error", err)
+		return false
+	}
+	if !started {
+		return false
+	}
+	slog.Info("Onboarding started", "user_id", user.ID)
+	return !replied && app.advanceOnboarding(user.ID, now)
+}
+
+// isOnboardingStepDue reports whether progress has a step to send at now
+func isOnboardingStepDue(progress *OnboardingProgress, steps []OnboardingStep, now time.Time) bool {
+	return progress.Status == onboardingStatusActive && progress.NextStep < len(steps) &&
+		!progress.StartedAt.Add(steps[progress.NextStep].Delay).After(now)
+}
+
+// advanceOnboarding sends the step that is due for userID and reports whether it did.
+// Like scheduled posts the step is marked before sending, after a downtime only the latest due step is sent.
+func (app *App) advanceOnboarding(userID int64, now time.Time) bool {
+	steps := app.config.Onboarding.Steps
+	index := -1
+	err := app.store.update(func(data *storeData) {
+		progress, ok := data.Onboarding[userID]
+		if !ok || progress.Status != onboardingStatusActive {
+			return
+		}
+		for progress.NextStep < len(steps) && !progress.StartedAt.Add(steps[progress.NextStep].Delay).After(now) {
+			index = progress.NextStep
+			progress.NextStep++
+		}
+		switch {
+		case index < 0:
+			return
+		case hasOrders(data, userID):
+			// Customers don't need to be talked into the first order
+			progress.Status = onboardingStatusOrdered
+			index = -1
+		case progress.NextStep == len(steps):
+			progress.Status = onboardingStatusDone
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving onboarding", "user_id", userID, "error", err)
+		return false
+	}
+	if index < 0 {
+		return false
+	}
+
+	err = app.sendOnboardingStep(userID, &steps[index])
+	var apiErr *ApiError
+	switch {
+	case err == nil:
+		slog.Info("Sent onboarding step", "user_id", userID, "step", index)
+		return true
+	case errors.As(err, &apiErr) && apiErr.Code == 403:
+		slog.Info("User blocked the bot, stopping onboarding", "user_id", userID)
+		app.setOnboardingStatus(userID, onboardingStatusBlocked)
+	default:
+		slog.Error("Error sending onboarding step", "user_id", userID, "step", index, "error", err)
+	}
+	return false
+}
+
+func (app *App) sendOnboardingStep(userID int64, step *OnboardingStep) error {
+	links := app.links()
+	params := map[string]any{
+		"chat_id":    userID,
+		"text":       renderLinks(step.Text, &links),
+		"parse_mode": "HTML",
+	}
+	if len(step.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(app.trackButtons(step.Buttons, userID))
+	}
+	if _, err := app.telegram.sendMessage(params); err != nil {
+		return err
+	}
+	if !step.Catalog || len(app.config.Products) == 0 {
+		return nil
+	}
+	params = app.catalogCategoriesView(0).params()
+	params["chat_id"] = userID
+	_, err := app.telegram.sendMessage(params)
+	return err
+}
+
+func (app *App) setOnboardingStatus(userID int64, status string) bool {
+	var changed bool
+	err := app.store.update(func(data *storeData) {
+		if progress, ok := data.Onboarding[userID]; ok && progress.Status == onboardingStatusActive {
+			progress.Status = status
+			changed = true
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving onboarding", "user_id", userID, "error", err)
+		return false
+	}
+	return changed
+}
+
+// runOnboarding sends the steps that became due since the last tick,
+// the store is only updated for the users who have one
+func (app *App) runOnboarding(now time.Time) {
+	if !app.config.Onboarding.Enabled {
+		return
+	}
+	var due []int64
+	app.store.view(func(data *storeData) {
+		for userID, progress := range data.Onboarding {
+			if isOnboardingStepDue(progress, app.config.Onboarding.Steps, now) {
+				due = append(due, userID)
+			}
+		}
+	})
+	for _, userID := range due {
+		app.advanceOnboarding(userID, now)
+	}
+}
+
+func (app *App) handleStopCommand(message *Message, args string) {
+	if app.setOnboardingStatus(message.From.ID, onboardingStatusStopped) {
+		slog.Info("Onboarding stopped", "user_id", message.From.ID)
+		app.replyText(message, "Хорошо, больше не будем присылать советы.")
+		return
+	}
+	app.replyText(message, "Советы вам не приходят.")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func onboardingTestConfig(config *Config) {
+	config.Products = []Product{
+		{ID: "lavender", Name: "Лавандовое мыло", Category: "Мыло", Price: 450, InStock: true},
+	}
+	config.Onboarding = Onboarding{
+		Enabled: true,
+		Steps: []OnboardingStep{
+			{Text: "Добро пожаловать! {soap}", Catalog: true},
+			{Delay: 48 * time.Hour, Text: "Как выбрать мыло под тип кожи", Buttons: []Button{{Text: "Читать", Url: "https://example.com/skin"}}},
+			{Delay: 7 * 24 * time.Hour, Text: "Промокод SOAP10"},
+		},
+	}
+}
+
+func sentTexts(api *fakeTelegramApi) []string {
+	var texts []string
+	for _, call := range api.callsTo("sendMessage") {
+		texts = append(texts, call.Params["text"].(string))
+	}
+	return texts
+}
+
+func onboardingStatus(app *App, userID int64) string {
+	var status string
+	app.store.view(func(data *storeData) {
+		if progress, ok := data.Onboarding[userID]; ok {
+			status = progress.Status
+		}
+	})
+	return status
+}
+
+func TestOnboardingSequence(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	user := User{ID: testCustomerID, FirstName: "Jane"}
+
+	if !app.startOnboarding(user, start, false) {
+		t.Fatal("Expected the first step to be sent right away")
+	}
+	texts := sentTexts(api)
+	if len(texts) != 2 || texts[0] != "Добро пожаловать! https://example.com/soap" || !strings.Contains(texts[1], "Выберите категорию") {
+		t.Fatalf("Expected the welcome and the catalog, got %q", texts)
+	}
+	if app.startOnboarding(user, start.Add(time.Hour), false) {
+		t.Error("Expected the sequence not to restart")
+	}
+
+	app.runOnboarding(start.Add(47 * time.Hour))
+	app.runOnboarding(start.Add(49 * time.Hour))
+	app.runOnboarding(start.Add(50 * time.Hour))
+	if texts := sentTexts(api); len(texts) != 3 || texts[2] != "Как выбрать мыло под тип кожи" {
+		t.Errorf("Expected the second step once, got %q", texts)
+	}
+
+	app.runOnboarding(start.Add(8 * 24 * time.Hour))
+	if texts := sentTexts(api); len(texts) != 4 || texts[3] != "Промокод SOAP10" {
+		t.Errorf("Expected the promo code, got %q", texts)
+	}
+	if status := onboardingStatus(app, user.ID); status != onboardingStatusDone {
+		t.Errorf("Expected done onboarding, got %s", status)
+	}
+}
+
+func TestOnboardingSendsLatestDueStep(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.startOnboarding(User{ID: testCustomerID}, start, false)
+	sent := len(api.callsTo("sendMessage"))
+
+	app.runOnboarding(start.Add(10 * 24 * time.Hour))
+
+	if texts := sentTexts(api)[sent:]; len(texts) != 1 || texts[0] != "Промокод SOAP10" {
+		t.Errorf("Expected only the latest step after a downtime, got %q", texts)
+	}
+}
+
+func TestOnboardingSkipsIdleUsers(t *testing.T) {
+	app, _ := newTestApp(t, onboardingTestConfig)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.startOnboarding(User{ID: testCustomerID}, start, false)
+
+	written := watchStoreWrites(t, app.store)
+	app.runOnboarding(start.Add(time.Hour))
+	if written() {
+		t.Error("Expected the store not to be rewritten when no step is due")
+	}
+	app.runOnboarding(start.Add(49 * time.Hour))
+	if !written() {
+		t.Error("Expected the due step to be saved")
+	}
+}
+
+func TestOnboardingAfterDeepLink(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+	app.config.Orders.Enabled = true
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/start order"))
+	if texts := sentTexts(api); len(texts) != 1 || strings.HasPrefix(texts[0], "Добро пожаловать") {
+		t.Errorf("Expected only the order to answer the deep link, got %q", texts)
+	}
+
+	app.store.view(func(data *storeData) {
+		progress := data.Onboarding[testCustomerID]
+		if progress == nil || progress.Status != onboardingStatusActive || progress.NextStep != 1 {
+			t.Errorf("Expected the user to enter onboarding after the first step, got %+v", progress)
+		}
+	})
+	app.runOnboarding(time.Now().Add(49 * time.Hour))
+	if text := lastSentText(t, api); text != "Как выбрать мыло под тип кожи" {
+		t.Errorf("Expected the second step, got %q", text)
+	}
+}
+
+func TestOnboardingSkipsCustomers(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.startOnboarding(User{ID: testCustomerID}, start, false)
+	addTestOrder(app, 1, testCustomerID, orderStatusNew)
+	sent := len(api.callsTo("sendMessage"))
+
+	app.runOnboarding(start.Add(49 * time.Hour))
+
+	if calls := api.callsTo("sendMessage"); len(calls) != sent {
+		t.Errorf("Expected no steps after an order, got %v", calls[sent:])
+	}
+	if status := onboardingStatus(app, testCustomerID); status != onboardingStatusOrdered {
+		t.Errorf("Expected ordered status, got %s", status)
+	}
+}
+
+func TestOnboardingStop(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/start"))
+	if texts := sentTexts(api); len(texts) != 2 || strings.Contains(texts[0], "/help") {
+		t.Errorf("Expected the first step instead of the list of commands, got %q", texts)
+	}
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/stop"))
+	if text := lastSentText(t, api); text != "Хорошо, больше не будем присылать советы." {
+		t.Errorf("Expected a confirmation, got %q", text)
+	}
+	sent := len(api.callsTo("sendMessage"))
+
+	app.runOnboarding(time.Now().Add(30 * 24 * time.Hour))
+	if calls := api.callsTo("sendMessage"); len(calls) != sent {
+		t.Errorf("Expected no steps after /stop, got %v", calls[sent:])
+	}
+	if status := onboardingStatus(app, testCustomerID); status != onboardingStatusStopped {
+		t.Errorf("Expected stopped status, got %s", status)
+	}
+}
+
+func TestOnboardingStopsForBlockedUsers(t *testing.T) {
+	app, api := newTestApp(t, onboardingTestConfig)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	app.startOnboarding(User{ID: testCustomerID}, start, false)
+	api.setResponse("sendMessage", `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`)
+
+	app.runOnboarding(start.Add(49 * time.Hour))
+
+	if status := onboardingStatus(app, testCustomerID); status != onboardingStatusBlocked {
+		t.Errorf("Expected blocked status, got %s", status)
+	}
+}
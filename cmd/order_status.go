@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	orderStatusCallbackPrefix = "status"
+
+	orderStatusAccepted  = "accepted"
+	orderStatusPaid      = "paid"
+	orderStatusShipped   = "shipped"
+	orderStatusCancelled = "cancelled"
+
+	maxTrackingNumberLength = 64
+)
+
+// orderTransitions lists the statuses an order may move to from each status,
+// shipped and cancelled orders are final
+var orderTransitions = map[string][]string{
+	orderStatusNew:      {orderStatusAccepted, orderStatusCancelled},
+	orderStatusAccepted: {orderStatusPaid, orderStatusCancelled},
+	orderStatusPaid:     {orderStatusShipped, orderStatusCancelled},
+}
+
+var orderStatusNames = map[string]string{
+	orderStatusNew:       "Новый",
+	orderStatusAccepted:  "Принят",
+	orderStatusPaid:      "Оплачен",
+	orderStatusShipped:   "Отправлен",
+	orderStatusCancelled: "Отменён",
+}
+
+var errOrderNotFound = errors.New("order not found")
+
+func canChangeOrderStatus(from string, to string) bool {
+	return slices.Contains(orderTransitions[from], to)
+}
+
+func orderStatusName(status string) string {
+	if name, ok := orderStatusNames[status]; ok {
+		return name
+	}
+	return status
+}
+
+// createCustomerStatusMessage is the DM a customer gets when admins change the status of their order
+func createCustomerStatusMessage(order *Order) string {
+	switch order.Status {
+	case orderStatusAccepted:
+		return fmt.Sprintf("Заказ №%d принят. Мы скоро напишем, как его оплатить.", order.ID)
+	case orderStatusPaid:
+		return fmt.Sprintf("Оплата заказа №%d получена, спасибо!", order.ID)
+	case orderStatusShipped:
+		return fmt.Sprintf("Заказ №%d отправлен. Трек-номер: %s", order.ID, order.TrackingNumber)
+	case orderStatusCancelled:
+		return fmt.Sprintf("Заказ №%d отменён. Если это ошибка, напишите нам.", order.ID)
+	}
+	return fmt.Sprintf("Статус заказа №%d: %s", order.ID, orderStatusName(order.Status))
+}
+
+func parseOrderStatusCallbackArgs(args callbackArgs) (orderID int64, status string, ok bool) {
+	orderID, ok = args.int64(0)
+	if !ok || len(args) != 2 {
+		return 0, "", false
+	}
+	if _, ok := orderStatusNames[args.string(1)]; !ok {
+		return 0, "", false
+	}
+	return orderID, args.string(1), true
+}
+
+// createOrderStatusMarkup has a button for every status the order can move to next
+func (app *App) createOrderStatusMarkup(order *Order) map[string]any {
+	var row []map[string]string
+	for _, status := range orderTransitions[order.Status] {
+		row = append(row, app.callbackButton(orderStatusName(status), orderStatusCallbackPrefix, order.ID, status))
+	}
+	keyboard := [][]map[string]string{}
+	if row != nil {
+		keyboard = append(keyboard, row)
+	}
+	return map[string]any{"inline_keyboard": keyboard}
+}
+
+// changeOrderStatus moves the order along orderTransitions and returns a copy of the updated record
+func (app *App) changeOrderStatus(orderID int64, status string, trackingNumber string, now time.Time) (Order, error) {
+	var updated Order
+	var changeErr error
+	err := app.store.update(func(data *storeData) {
+		index := slices.IndexFunc(data.Orders, func(order *Order) bool { return order.ID == orderID })
+		if index < 0 {
+			changeErr = errOrderNotFound
+			return
+		}
+		order := data.Orders[index]
+		if !canChangeOrderStatus(order.Status, status) {
+			changeErr = fmt.Errorf("order %d can't change from %s to %s", orderID, order.Status, status)
+			return
+		}
+		order.Status = status
+		order.UpdatedAt = now
+		if trackingNumber != "" {
+			order.TrackingNumber = trackingNumber
+		}
+		updated = *order
+	})
+	if changeErr != nil {
+		return updated, changeErr
+	}
+	return updated, err
+}
+
+func (app *App) findOrder(orderID int64) (Order, bool) {
+	var found Order
+	var ok bool
+	app.store.view(func(data *storeData) {
+		index := slices.IndexFunc(data.Orders, func(order *Order) bool { return order.ID == orderID })
+		if index >= 0 {
+			found, ok = *data.Orders[index], true
+		}
+	})
+	return found, ok
+}
+
+func (app *App) notifyCustomer(order *Order) {
+	_, err := app.telegram.sendMessage(map[string]any{
+		"chat_id": order.Customer.ID,
+		"text":    createCustomerStatusMessage(order),
+	})
+	if err != nil {
+		slog.Error("Error notifying customer about order status", "order_id", order.ID, "error", err)
+	}
+}
+
+// refreshOrderNotification updates the order message in the admin chat with the new status and buttons
+func (app *App) refreshOrderNotification(order *Order) {
+	if order.AdminMessageID == 0 {
+		return
+	}
+	err := app.telegram.editMessageText(map[string]any{
+		"chat_id":      app.config.AdminChatID,
+		"message_id":   order.AdminMessageID,
+		"text":         createOrderNotification(order),
+		"parse_mode":   "HTML",
+		"reply_markup": app.createOrderStatusMarkup(order),
+	})
+	if err != nil {
+		slog.Error("Error updating order notification", "order_id", order.ID, "error", err)
+	}
+}
+
+func (app *App) applyOrderStatus(orderID int64, status string, trackingNumber string) (Order, error) {
+	order, err := app.changeOrderStatus(orderID, status, trackingNumber, time.Now())
+	if err != nil {
+		return order, err
+	}
+	slog.Info("Order status changed", "order_id", order.ID, "status", order.Status)
+	app.refreshOrderNotification(&order)
+	app.notifyCustomer(&order)
+	return order, nil
+}
+
+func (app *App) handleOrderStatusCallback(query *CallbackQuery, args callbackArgs) string {
+	orderID, status, ok := parseOrderStatusCallbackArgs(args)
+	if !ok || query.Message == nil || !app.isAdminChat(query.Message.Chat.ID) {
+		return ""
+	}
+	order, ok := app.findOrder(orderID)
+	if !ok {
+		return "Заказ не найден"
+	}
+	if !canChangeOrderStatus(order.Status, status) {
+		return "Статус заказа уже «" + orderStatusName(order.Status) + "»"
+	}
+
+	// Shipping needs a tracking number, it's asked for with a reply to a prompt
+	if status == orderStatusShipped {
+		app.askTrackingNumber(&order)
+		return ""
+	}
+
+	if _, err := app.applyOrderStatus(orderID, status, ""); err != nil {
+		slog.Error("Error changing order status", "order_id", orderID, "error", err)
+		return "Не получилось изменить статус"
+	}
+	return orderStatusName(status)
+}
+
+func (app *App) askTrackingNumber(order *Order) {
+	prompt, err := app.telegram.sendMessage(map[string]any{
+		"chat_id":      app.config.AdminChatID,
+		"text":         fmt.Sprintf("Ответьте на это сообщение трек-номером заказа №%d.", order.ID),
+		"reply_markup": map[string]any{"force_reply": true, "input_field_placeholder": "Трек-номер"},
+	})
+	if err != nil {
+		slog.Error("Error asking for tracking number", "order_id", order.ID, "error", err)
+		return
+	}
+	err = app.store.update(func(data *storeData) {
+		data.TrackingPrompts[prompt.MessageID] = order.ID
+	})
+	if err != nil {
+		slog.Error("Error saving tracking number prompt", "order_id", order.ID, "error", err)
+	}
+}
+
+func (app *App) trackingPromptOrderID(message *Message) (int64, bool) {
+	if message == nil || message.ReplyToMessage == nil || !app.isAdminChat(message.Chat.ID) {
+		return 0, false
+	}
+	var orderID int64
+	var ok bool
+	app.store.view(func(data *storeData) {
+		orderID, ok = data.TrackingPrompts[message.ReplyToMessage.MessageID]
+	})
+	return orderID, ok
+}
+
+func (app *App) isTrackingNumberReply(message *Message) bool {
+	_, ok := app.trackingPromptOrderID(message)
+	return ok
+}
+
+func (app *App) handleTrackingNumberReply(message *Message) {
+	orderID, _ := app.trackingPromptOrderID(message)
+	trackingNumber := strings.TrimSpace(message.Text)
+	if trackingNumber == "" || len(trackingNumber) > maxTrackingNumberLength {
+		app.replyText(message, "Пришлите трек-номер текстом в ответ на сообщение.")
+		return
+	}
+
+	order, err := app.applyOrderStatus(orderID, orderStatusShipped, trackingNumber)
+	// The prompt is answered either way, an order that was cancelled meanwhile can't be shipped later
+	if err := app.store.update(func(data *storeData) {
+		delete(data.TrackingPrompts, message.ReplyToMessage.MessageID)
+	}); err != nil {
+		slog.Error("Error removing tracking number prompt", "order_id", orderID, "error", err)
+	}
+	if err != nil {
+		slog.Error("Error shipping order", "order_id", orderID, "error", err)
+		app.replyText(message, fmt.Sprintf("Не получилось отметить заказ №%d отправленным.", orderID))
+		return
+	}
+	app.replyText(message, fmt.Sprintf("Заказ №%d отправлен, покупатель получил трек-номер %s.", order.ID, order.TrackingNumber))
+}
+
+func createCustomerOrdersList(orders []Order) string {
+	if len(orders) == 0 {
+		return "У вас пока нет заказов. Оформить заказ: /order"
+	}
+	lines := []string{"Ваши заказы:"}
+	for _, order := range orders {
+		line := fmt.Sprintf("№%d от %s — %s — %s", order.ID, order.CreatedAt.Format("02.01.2006"), formatPrice(order.total()), orderStatusName(order.Status))
+		if order.TrackingNumber != "" {
+			line += ", трек-номер " + html.EscapeString(order.TrackingNumber)
+		}
+		lines = append(lines, "", line, createOrderItemsList(order.Items))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) handleMyOrdersCommand(message *Message, args string) {
+	if message.Chat.Type != "private" {
+		app.replyText(message, "Список заказов можно посмотреть в личных сообщениях с ботом.")
+		return
+	}
+	var orders []Order
+	app.store.view(func(data *storeData) {
+		for _, order := range data.Orders {
+			if order.Customer.ID == message.From.ID {
+				orders = append(orders, *order)
+			}
+		}
+	})
+	app.reply(message, map[string]any{
+		"text":       createCustomerOrdersList(orders),
+		"parse_mode": "HTML",
+	})
+}
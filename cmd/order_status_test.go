@@ -0,0 +1,197 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func addTestOrder(app *App, id int64, customerID int64, status string) {
+	app.store.update(func(data *storeData) {
+		data.Orders = append(data.Orders, &Order{
+			ID:             id,
+			Customer:       User{ID: customerID, FirstName: "Jane"},
+			OrderDetails:   OrderDetails{Items: []OrderItem{{ProductID: "lavender", Name: "Лавандовое мыло", Price: 450, Quantity: 2}}},
+			Status:         status,
+			CreatedAt:      time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC),
+			AdminMessageID: 10,
+		})
+	})
+}
+
+func createTestStatusCallback(app *App, payload string, chatID int64) *Update {
+	return &Update{CallbackQuery: &CallbackQuery{
+		ID:      "query",
+		From:    User{ID: 777, FirstName: "Admin"},
+		Data:    app.callbacks.sign(payload),
+		Message: &Message{MessageID: 10, Chat: Chat{ID: chatID, Type: "supergroup"}},
+	}}
+}
+
+func TestCanChangeOrderStatus(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{from: orderStatusNew, to: orderStatusAccepted, expected: true},
+		{from: orderStatusNew, to: orderStatusCancelled, expected: true},
+		{from: orderStatusNew, to: orderStatusPaid, expected: false},
+		{from: orderStatusAccepted, to: orderStatusPaid, expected: true},
+		{from: orderStatusPaid, to: orderStatusShipped, expected: true},
+		{from: orderStatusShipped, to: orderStatusCancelled, expected: false},
+		{from: orderStatusCancelled, to: orderStatusAccepted, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if result := canChangeOrderStatus(tt.from, tt.to); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseOrderStatusCallbackArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           callbackArgs
+		expectedID     int64
+		expectedStatus string
+		expectedOk     bool
+	}{
+		{name: "status", args: callbackArgs{"c", "paid"}, expectedID: 12, expectedStatus: orderStatusPaid, expectedOk: true},
+		{name: "unknown status", args: callbackArgs{"c", "lost"}, expectedOk: false},
+		{name: "malformed id", args: callbackArgs{"a-b", "paid"}, expectedOk: false},
+		{name: "missing status", args: callbackArgs{"c"}, expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orderID, status, ok := parseOrderStatusCallbackArgs(tt.args)
+			if orderID != tt.expectedID || status != tt.expectedStatus || ok != tt.expectedOk {
+				t.Errorf("Expected %d %q %v, got %d %q %v", tt.expectedID, tt.expectedStatus, tt.expectedOk, orderID, status, ok)
+			}
+		})
+	}
+}
+
+func TestCreateOrderStatusMarkup(t *testing.T) {
+	app, _ := newTestApp(t, ordersTestConfig)
+	markup := app.createOrderStatusMarkup(&Order{ID: 3, Status: orderStatusNew})
+	data := keyboardData(app, markup["inline_keyboard"].([][]map[string]string))
+	if strings.Join(data, ",") != "status:3:accepted,status:3:cancelled" {
+		t.Errorf("Expected accept and cancel buttons, got %v", data)
+	}
+
+	markup = app.createOrderStatusMarkup(&Order{ID: 3, Status: orderStatusShipped})
+	if len(markup["inline_keyboard"].([][]map[string]string)) != 0 {
+		t.Error("Expected no buttons for a shipped order")
+	}
+}
+
+func TestOrderStatusCallback(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	addTestOrder(app, 1, testCustomerID, orderStatusNew)
+
+	app.handleTelegramUpdate(createTestStatusCallback(app, "status:1:accepted", 123456789))
+	if order, _ := app.findOrder(1); order.Status != orderStatusNew {
+		t.Error("Expected buttons outside the admin chat to be ignored")
+	}
+
+	app.handleTelegramUpdate(createTestStatusCallback(app, "status:1:paid", 555))
+	if order, _ := app.findOrder(1); order.Status != orderStatusNew {
+		t.Error("Expected a skipped status to be rejected")
+	}
+
+	app.handleTelegramUpdate(createTestStatusCallback(app, "status:1:accepted", 555))
+	order, _ := app.findOrder(1)
+	if order.Status != orderStatusAccepted {
+		t.Errorf("Expected accepted order, got %s", order.Status)
+	}
+
+	messages := api.callsTo("sendMessage")
+	if len(messages) != 1 || messages[0].Params["chat_id"] != float64(testCustomerID) || !strings.Contains(messages[0].Params["text"].(string), "Заказ №1 принят") {
+		t.Errorf("Expected the customer to be notified, got %v", messages)
+	}
+	edits := api.callsTo("editMessageText")
+	if len(edits) != 1 || edits[0].Params["message_id"] != float64(10) || !strings.Contains(edits[0].Params["text"].(string), "Статус: Принят") {
+		t.Errorf("Expected the admin notification to be updated, got %v", edits)
+	}
+}
+
+func TestShipOrderWithTrackingNumber(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	addTestOrder(app, 1, testCustomerID, orderStatusPaid)
+	api.setResponse("sendMessage", `{"ok": true, "result": {"message_id": 77}}`)
+
+	app.handleTelegramUpdate(createTestStatusCallback(app, "status:1:shipped", 555))
+	if order, _ := app.findOrder(1); order.Status != orderStatusPaid {
+		t.Error("Expected the order to wait for a tracking number")
+	}
+	prompt := api.callsTo("sendMessage")[0].Params
+	if prompt["chat_id"] != float64(555) || prompt["reply_markup"].(map[string]any)["force_reply"] != true {
+		t.Errorf("Expected a force reply prompt in the admin chat, got %v", prompt)
+	}
+
+	app.handleTelegramUpdate(&Update{Message: &Message{
+		Text:           "RA123456789RU",
+		Chat:           Chat{ID: 555, Type: "supergroup"},
+		From:           User{ID: 777},
+		ReplyToMessage: &Message{MessageID: 77},
+	}})
+
+	order, _ := app.findOrder(1)
+	if order.Status != orderStatusShipped || order.TrackingNumber != "RA123456789RU" {
+		t.Errorf("Expected shipped order with tracking number, got %+v", order)
+	}
+	var customerMessage string
+	for _, call := range api.callsTo("sendMessage") {
+		if call.Params["chat_id"] == float64(testCustomerID) {
+			customerMessage = call.Params["text"].(string)
+		}
+	}
+	if !strings.Contains(customerMessage, "Трек-номер: RA123456789RU") {
+		t.Errorf("Expected the customer to get the tracking number, got %q", customerMessage)
+	}
+	app.store.view(func(data *storeData) {
+		if len(data.TrackingPrompts) != 0 {
+			t.Error("Expected the prompt to be removed")
+		}
+	})
+}
+
+func TestMyOrdersCommand(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	addTestOrder(app, 1, testCustomerID, orderStatusShipped)
+	addTestOrder(app, 2, 999, orderStatusNew)
+	app.store.update(func(data *storeData) {
+		data.Orders[0].TrackingNumber = "RA123456789RU"
+	})
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/myorders"))
+
+	text := lastSentText(t, api)
+	for _, expected := range []string{"№1 от 08.03.2026 — 900 ₽ — Отправлен, трек-номер RA123456789RU", "Лавандовое мыло × 2"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected %q in the list, got %s", expected, text)
+		}
+	}
+	if strings.Contains(text, "№2") {
+		t.Errorf("Expected only the customer's orders, got %s", text)
+	}
+}
+
+func TestMyOrdersCommandWithoutOrders(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/myorders"))
+	if text := lastSentText(t, api); !strings.Contains(text, "У вас пока нет заказов") {
+		t.Errorf("Expected empty list, got %s", text)
+	}
+
+	app.handleTelegramUpdate(createTestCommand("/myorders"))
+	if text := lastSentText(t, api); !strings.Contains(text, "в личных сообщениях") {
+		t.Errorf("Expected the list to stay private, got %s", text)
+	}
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	orderCallbackPrefix = "order"
+	orderStartPayload   = "order"
+
+	orderStepProducts = "products"
+	orderStepDelivery = "delivery"
+	orderStepCity     = "city"
+	orderStepPhone    = "phone"
+	orderStepConfirm  = "confirm"
+
+	orderStatusNew = "new"
+
+	maxOrderQuantity   = 99
+	maxCityLength      = 100
+	orderExpiryPeriod  = time.Minute
+	orderExpiredNotice = "Время оформления заказа истекло. Чтобы начать заново, отправьте /order."
+)
+
+var phonePattern = regexp.MustCompile(`^\+[0-9]{10,15}$`)
+
+type OrderItem struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"name"`
+	Price     int    `json:"price"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OrderDetails are filled in step by step during the /order conversation
+type OrderDetails struct {
+	Items    []OrderItem `json:"items"`
+	Delivery string      `json:"delivery,omitempty"`
+	City     string      `json:"city,omitempty"`
+	Phone    string      `json:"phone,omitempty"`
+}
+
+// OrderDraft is an order being put together in a private chat, users have at most one
+type OrderDraft struct {
+	Step string `json:"step"`
+	OrderDetails
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Order struct {
+	ID       int64 `json:"id"`
+	Customer User  `json:"customer"`
+	OrderDetails
+	Status         string    `json:"status"`
+	TrackingNumber string    `json:"tracking_number,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// AdminMessageID is the notification in the admin chat that carries the status buttons
+	AdminMessageID int64 `json:"admin_message_id,omitempty"`
+}
+
+func (d *OrderDetails) total() int {
+	total := 0
+	for _, item := range d.Items {
+		total += item.Price * item.Quantity
+	}
+	return total
+}
+
+func (d *OrderDetails) quantity(productID string) int {
+	for _, item := range d.Items {
+		if item.ProductID == productID {
+			return item.Quantity
+		}
+	}
+	return 0
+}
+
+// changeQuantity adds delta pieces of product to the cart, items that drop to zero are removed
+func (d *OrderDetails) changeQuantity(product *Product, delta int) {
+	index := slices.IndexFunc(d.Items, func(item OrderItem) bool { return item.ProductID == product.ID })
+	if index < 0 {
+		if delta > 0 {
+			d.Items = append(d.Items, OrderItem{ProductID: product.ID, Name: product.Name, Price: product.Price, Quantity: min(delta, maxOrderQuantity)})
+		}
+		return
+	}
+	d.Items[index].Quantity = min(d.Items[index].Quantity+delta, maxOrderQuantity)
+	if d.Items[index].Quantity <= 0 {
+		d.Items = slices.Delete(d.Items, index, index+1)
+	}
+}
+
+func isOrderDraftExpired(draft *OrderDraft, timeout time.Duration, now time.Time) bool {
+	return now.Sub(draft.UpdatedAt) > timeout
+}
+
+// normalizePhone accepts numbers like "8 (912) 345-67-89" or "79123456789" and returns them as "+79123456789"
+func normalizePhone(text string) (string, bool) {
+	phone := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -()", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(text))
+	switch {
+	case len(phone) == 11 && strings.HasPrefix(phone, "8"):
+		phone = "7" + phone[1:]
+	case len(phone) == 10 && strings.HasPrefix(phone, "9"):
+		// A Russian mobile number without the country code
+		phone = "7" + phone
+	}
+	if !strings.HasPrefix(phone, "+") {
+		phone = "+" + phone
+	}
+	if !phonePattern.MatchString(phone) {
+		return "", false
+	}
+	return phone, true
+}
+
+func createOrderItemsList(items []OrderItem) string {
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, fmt.Sprintf("• %s × %d — %s", html.EscapeString(item.Name), item.Quantity, formatPrice(item.Price*item.Quantity)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func createOrderSummary(details *OrderDetails) string {
+	lines := []string{
+		createOrderItemsList(details.Items),
+		"Итого: " + formatPrice(details.total()),
+	}
+	if details.Delivery != "" {
+		lines = append(lines, "", "Доставка: "+html.EscapeString(details.Delivery))
+	}
+	if details.City != "" {
+		lines = append(lines, "Город: "+html.EscapeString(details.City))
+	}
+	if details.Phone != "" {
+		lines = append(lines, "Телефон: "+details.Phone)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func createOrderNotification(order *Order) string {
+	text := fmt.Sprintf("Заказ №%d от %s\n\n%s\n\nСтатус: %s",
+		order.ID, formatUserMention(&order.Customer), createOrderSummary(&order.OrderDetails), orderStatusName(order.Status))
+	if order.TrackingNumber != "" {
+		text += "\nТрек-номер: " + html.EscapeString(order.TrackingNumber)
+	}
+	return text
+}
+
+func (app *App) orderCancelButton() map[string]string {
+	return app.callbackButton("Отмена", orderCallbackPrefix, "cancel")
+}
+
+func (app *App) orderableProducts() []Product {
+	var products []Product
+	for _, product := range app.config.Products {
+		if product.InStock {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+func (app *App) orderProductsView(draft *OrderDraft) (string, [][]map[string]string) {
+	text := "Выберите товары, каждое нажатие добавляет одну штуку.\n\n"
+	if len(draft.Items) == 0 {
+		text += "Корзина пуста."
+	} else {
+		text += createOrderItemsList(draft.Items) + "\nИтого: " + formatPrice(draft.total())
+	}
+
+	var keyboard [][]map[string]string
+	for _, product := range app.orderableProducts() {
+		quantity := draft.quantity(product.ID)
+		label := product.Name + " — " + formatPrice(product.Price)
+		if quantity > 0 {
+			label = fmt.Sprintf("%s × %d", product.Name, quantity)
+		}
+		row := []map[string]string{app.callbackButton(label, orderCallbackPrefix, "add", product.ID)}
+		if quantity > 0 {
+			row = append(row, app.callbackButton("−", orderCallbackPrefix, "sub", product.ID))
+		}
+		keyboard = append(keyboard, row)
+	}
+	keyboard = append(keyboard, []map[string]string{app.orderCancelButton(), app.callbackButton("Далее »", orderCallbackPrefix, "next")})
+	return text, keyboard
+}
+
+func (app *App) orderDeliveryView(draft *OrderDraft) (string, [][]map[string]string) {
+	var keyboard [][]map[string]string
+	for i, method := range app.config.Orders.Delivery {
+		keyboard = append(keyboard, []map[string]string{app.callbackButton(method, orderCallbackPrefix, "delivery", i)})
+	}
+	keyboard = append(keyboard, []map[string]string{app.orderCancelButton()})
+	return createOrderSummary(&draft.OrderDetails) + "\n\nКак доставить заказ?", keyboard
+}
+
+func (app *App) orderConfirmView(draft *OrderDraft) (string, [][]map[string]string) {
+	keyboard := [][]map[string]string{
+		{app.orderCancelButton(), app.callbackButton("Подтвердить", orderCallbackPrefix, "confirm")},
+	}
+	return "Проверьте заказ:\n\n" + createOrderSummary(&draft.OrderDetails), keyboard
+}
+
+// orderDraft returns a copy of the conversation state of userID
+func (app *App) orderDraft(userID int64) (OrderDraft, bool) {
+	var draft OrderDraft
+	var ok bool
+	app.store.view(func(data *storeData) {
+		var stored *OrderDraft
+		stored, ok = data.OrderDrafts[userID]
+		if ok {
+			draft = *stored
+			draft.Items = slices.Clone(stored.Items)
+		}
+	})
+	return draft, ok
+}
+
+func (app *App) saveOrderDraft(userID int64, draft OrderDraft, now time.Time) {
+	draft.UpdatedAt = now
+	err := app.store.update(func(data *storeData) {
+		data.OrderDrafts[userID] = &draft
+	})
+	if err != nil {
+		slog.Error("Error saving order draft", "user_id", userID, "error", err)
+	}
+}
+
+func (app *App) deleteOrderDraft(userID int64) bool {
+	var ok bool
+	err := app.store.update(func(data *storeData) {
+		_, ok = data.OrderDrafts[userID]
+		delete(data.OrderDrafts, userID)
+	})
+	if err != nil {
+		slog.Error("Error deleting order draft", "user_id", userID, "error", err)
+	}
+	return ok
+}
+
+// activeOrderDraft returns the draft of userID unless it timed out, expired drafts are dropped
+// and the user is told so
+func (app *App) activeOrderDraft(userID int64, now time.Time) (OrderDraft, bool) {
+	draft, ok := app.orderDraft(userID)
+	if !ok {
+		return draft, false
+	}
+	if isOrderDraftExpired(&draft, app.config.Orders.Timeout, now) {
+		app.deleteOrderDraft(userID)
+		app.sendOrderMessage(userID, map[string]any{"text": orderExpiredNotice})
+		return draft, false
+	}
+	return draft, true
+}
+
+func (app *App) sendOrderMessage(chatID int64, params map[string]any) {
+	params["chat_id"] = chatID
+	if _, err := app.telegram.sendMessage(params); err != nil {
+		slog.Error("Error sending order message", "chat_id", chatID, "error", err)
+	}
+}
+
+func (app *App) sendOrderView(chatID int64, text string, keyboard [][]map[string]string) {
+	app.sendOrderMessage(chatID, map[string]any{
+		"text":         text,
+		"parse_mode":   "HTML",
+		"reply_markup": map[string]any{"inline_keyboard": keyboard},
+	})
+}
+
+func (app *App) editOrderView(message *Message, text string, keyboard [][]map[string]string) {
+	params := map[string]any{
+		"chat_id":    message.Chat.ID,
+		"message_id": message.MessageID,
+		"text":       text,
+		"parse_mode": "HTML",
+	}
+	if keyboard != nil {
+		params["reply_markup"] = map[string]any{"inline_keyboard": keyboard}
+	}
+	if err := app.telegram.editMessageText(params); err != nil {
+		slog.Error("Error updating order message", "chat_id", message.Chat.ID, "error", err)
+	}
+}
+
+func (app *App) orderDeepLink(payload string) string {
+	if !app.config.Orders.Enabled {
+		return ""
+	}
+	return app.deepLink(payload)
+}
+
+// startOrder begins a new conversation in the private chat with userID,
+// a product from a deep link is put into the cart right away
+func (app *App) startOrder(userID int64, productID string, now time.Time) {
+	products := app.orderableProducts()
+	if len(products) == 0 {
+		app.sendOrderMessage(userID, map[string]any{"text": "Сейчас нет товаров в наличии."})
+		return
+	}
+
+	draft := OrderDraft{Step: orderStepProducts}
+	if index := slices.IndexFunc(products, func(product Product) bool { return product.ID == productID }); index >= 0 {
+		draft.changeQuantity(&products[index], 1)
+	}
+	app.saveOrderDraft(userID, draft, now)
+	text, keyboard := app.orderProductsView(&draft)
+	app.sendOrderView(userID, text, keyboard)
+}
+
+func (app *App) handleOrderCommand(message *Message, args string) {
+	if message.Chat.Type != "private" {
+		params := map[string]any{"text": "Оформить заказ можно в личных сообщениях с ботом."}
+		if link := app.orderDeepLink(orderStartPayload); link != "" {
+			params["reply_markup"] = createCustomButtonsMarkup([]Button{{Text: "Оформить заказ", Url: link}})
+		}
+		app.reply(message, params)
+		return
+	}
+	app.startOrder(message.From.ID, "", time.Now())
+}
+
+func (app *App) handleCancelCommand(message *Message, args string) {
+	text := "Нечего отменять."
+	if app.deleteOrderDraft(message.From.ID) {
+		text = "Оформление заказа отменено."
+	}
+	app.reply(message, map[string]any{
+		"text":         text,
+		"reply_markup": map[string]any{"remove_keyboard": true},
+	})
+}
+
+func (app *App) isOrderMessage(message *Message) bool {
+	if !app.config.Orders.Enabled || message == nil || message.Chat.Type != "private" || isCommand(message) {
+		return false
+	}
+	_, ok := app.orderDraft(message.From.ID)
+	return ok
+}
+
+func (app *App) handleOrderMessage(message *Message) {
+	now := time.Now()
+	userID := message.From.ID
+	draft, ok := app.activeOrderDraft(userID, now)
+	if !ok {
+		return
+	}
+
+	switch draft.Step {
+	case orderStepCity:
+		city := strings.TrimSpace(message.Text)
+		if city == "" || len([]rune(city)) > maxCityLength {
+			app.replyText(message, "Напишите название города текстом.")
+			return
+		}
+		draft.City = city
+		draft.Step = orderStepPhone
+		app.saveOrderDraft(userID, draft, now)
+		app.sendOrderMessage(userID, map[string]any{
+			"text": "Оставьте номер телефона для связи: нажмите кнопку ниже или напишите его.",
+			"reply_markup": map[string]any{
+				"keyboard":          [][]map[string]any{{{"text": "Отправить номер", "request_contact": true}}},
+				"resize_keyboard":   true,
+				"one_time_keyboard": true,
+			},
+		})
+	case orderStepPhone:
+		phoneText := message.Text
+		// Only the user's own contact is accepted from the button, shared contacts of others are treated as text
+		if message.Contact != nil && message.Contact.UserID == userID {
+			phoneText = message.Contact.PhoneNumber
+		}
+		phone, ok := normalizePhone(phoneText)
+		if !ok {
+			app.replyText(message, "Не получилось распознать номер. Напишите его в формате +79123456789.")
+			return
+		}
+		draft.Phone = phone
+		draft.Step = orderStepConfirm
+		app.saveOrderDraft(userID, draft, now)
+		app.sendOrderMessage(userID, map[string]any{
+			"text":         "Спасибо, номер сохранён.",
+			"reply_markup": map[string]any{"remove_keyboard": true},
+		})
+		text, keyboard := app.orderConfirmView(&draft)
+		app.sendOrderView(userID, text, keyboard)
+	default:
+		app.replyText(message, "Выберите вариант кнопками в сообщении выше или отправьте /cancel, чтобы отменить заказ.")
+	}
+}
+
+func (app *App) handleOrderCallback(query *CallbackQuery, args callbackArgs) string {
+	if query.Message == nil {
+		return ""
+	}
+	now := time.Now()
+	userID := query.From.ID
+	draft, ok := app.activeOrderDraft(userID, now)
+	if !ok {
+		return "Этот заказ уже не оформляется"
+	}
+
+	action := args.string(0)
+	switch {
+	case action == "cancel":
+		app.deleteOrderDraft(userID)
+		app.editOrderView(query.Message, "Оформление заказа отменено.", nil)
+	case (action == "add" || action == "sub") && draft.Step == orderStepProducts:
+		products := app.orderableProducts()
+		index := slices.IndexFunc(products, func(product Product) bool { return product.ID == args.string(1) })
+		if index < 0 {
+			return "Этого товара больше нет в наличии"
+		}
+		delta := 1
+		if action == "sub" {
+			delta = -1
+		}
+		draft.changeQuantity(&products[index], delta)
+		app.saveOrderDraft(userID, draft, now)
+		text, keyboard := app.orderProductsView(&draft)
+		app.editOrderView(query.Message, text, keyboard)
+	case action == "next" && draft.Step == orderStepProducts:
+		if len(draft.Items) == 0 {
+			return "Добавьте хотя бы один товар"
+		}
+		draft.Step = orderStepDelivery
+		app.saveOrderDraft(userID, draft, now)
+		text, keyboard := app.orderDeliveryView(&draft)
+		app.editOrderView(query.Message, text, keyboard)
+	case action == "delivery" && draft.Step == orderStepDelivery:
+		index, ok := args.int(1)
+		if !ok || index < 0 || index >= len(app.config.Orders.Delivery) {
+			return ""
+		}
+		draft.Delivery = app.config.Orders.Delivery[index]
+		draft.Step = orderStepCity
+		app.saveOrderDraft(userID, draft, now)
+		app.editOrderView(query.Message, createOrderSummary(&draft.OrderDetails), nil)
+		app.sendOrderMessage(userID, map[string]any{"text": "В какой город доставить заказ?"})
+	case action == "confirm" && draft.Step == orderStepConfirm:
+		order, err := app.createOrder(&query.From, now)
+		if err != nil {
+			app.sendOrderMessage(userID, map[string]any{"text": "Не получилось сохранить заказ, попробуйте ещё раз."})
+			return ""
+		}
+		if order == nil {
+			// A second tap on the button that arrived while the first one was saving the order
+			return "Заказ уже оформлен"
+		}
+		app.editOrderView(query.Message, fmt.Sprintf("Заказ №%d оформлен!\n\n%s\n\nМы свяжемся с вами, чтобы подтвердить заказ.",
+			order.ID, createOrderSummary(&order.OrderDetails)), nil)
+		app.notifyAdminsAboutOrder(order)
+	default:
+		// Buttons of a step the conversation has already left
+	}
+	return ""
+}
+
+// createOrder turns the confirmed draft into an order record and ends the conversation,
+// it returns nil when the draft was already turned into an order
+func (app *App) createOrder(customer *User, now time.Time) (*Order, error) {
+	var order *Order
+	err := app.store.update(func(data *storeData) {
+		draft, ok := data.OrderDrafts[customer.ID]
+		if !ok || draft.Step != orderStepConfirm {
+			return
+		}
+		data.LastOrderID++
+		order = &Order{
+			ID:           data.LastOrderID,
+			Customer:     *customer,
+			OrderDetails: draft.OrderDetails,
+			Status:       orderStatusNew,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		data.Orders = append(data.Orders, order)
+		delete(data.OrderDrafts, customer.ID)
+	})
+	if err != nil {
+		slog.Error("Error saving order", "user_id", customer.ID, "error", err)
+		return nil, err
+	}
+	if order == nil {
+		return nil, nil
+	}
+	slog.Info("Order created", "order_id", order.ID, "user_id", customer.ID, "total", order.total())
+	return order, nil
+}
+
+func (app *App) notifyAdminsAboutOrder(order *Order) {
+	if app.config.AdminChatID == 0 {
+		slog.Warn("ADMIN_CHAT_ID is not set, order is only saved", "order_id", order.ID)
+		return
+	}
+	message, err := app.telegram.sendMessage(map[string]any{
+		"chat_id":      app.config.AdminChatID,
+		"text":         createOrderNotification(order),
+		"parse_mode":   "HTML",
+		"reply_markup": app.createOrderStatusMarkup(order),
+	})
+	if err != nil {
+		slog.Error("Error notifying admins about order", "order_id", order.ID, "error", err)
+		return
+	}
+	err = app.store.update(func(data *storeData) {
+		if index := slices.IndexFunc(data.Orders, func(stored *Order) bool { return stored.ID == order.ID }); index >= 0 {
+			data.Orders[index].AdminMessageID = message.MessageID
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving order notification", "order_id", order.ID, "error", err)
+	}
+}
+
+// expireOrderDrafts drops conversations that were idle for longer than the timeout
+func (app *App) expireOrderDrafts(now time.Time) {
+	// Most ticks find nothing to expire, so the store is only rewritten when there is something
+	var found bool
+	app.store.view(func(data *storeData) {
+		for _, draft := range data.OrderDrafts {
+			if isOrderDraftExpired(draft, app.config.Orders.Timeout, now) {
+				found = true
+				return
+			}
+		}
+	})
+	if !found {
+		return
+	}
+
+	var expired []int64
+	err := app.store.update(func(data *storeData) {
+		for userID, draft := range data.OrderDrafts {
+			if isOrderDraftExpired(draft, app.config.Orders.Timeout, now) {
+				expired = append(expired, userID)
+				delete(data.OrderDrafts, userID)
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error expiring order drafts", "error", err)
+	}
+	for _, userID := range expired {
+		app.sendOrderMessage(userID, map[string]any{
+			"text":         orderExpiredNotice,
+			"reply_markup": map[string]any{"remove_keyboard": true},
+		})
+	}
+}
+
+func (app *App) startOrderExpiry(ctx context.Context) {
+	if !app.config.Orders.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(orderExpiryPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				app.expireOrderDrafts(now)
+			}
+		}
+	}()
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCustomerID is the sender of createTestPrivateMessage
+const testCustomerID = 111222333
+
+func ordersTestConfig(config *Config) {
+	config.Products = []Product{
+		{ID: "lavender", Name: "Лавандовое мыло", Category: "Мыло", Price: 450, InStock: true},
+		{ID: "mint", Name: "Мятное мыло", Category: "Мыло", Price: 400},
+		{ID: "rose", Name: "Гидролат розы", Category: "Гидролаты", Price: 600, InStock: true},
+	}
+	config.Orders = Orders{Enabled: true, Delivery: []string{"Самовывоз", "СДЭК"}, Timeout: 30 * time.Minute}
+}
+
+// createTestOrderCallback presses a button with the "order:..." payload
+func createTestOrderCallback(app *App, payload string) *Update {
+	return &Update{CallbackQuery: &CallbackQuery{
+		ID:      "query",
+		From:    User{ID: testCustomerID, FirstName: "Jane"},
+		Data:    app.callbacks.sign(payload),
+		Message: &Message{MessageID: 42, Chat: Chat{ID: testCustomerID, Type: "private"}},
+	}}
+}
+
+func lastSentText(t *testing.T, api *fakeTelegramApi) string {
+	t.Helper()
+	calls := api.callsTo("sendMessage")
+	if len(calls) == 0 {
+		t.Fatal("Expected a message to be sent")
+	}
+	return calls[len(calls)-1].Params["text"].(string)
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		text       string
+		expected   string
+		expectedOk bool
+	}{
+		{text: "+7 912 345-67-89", expected: "+79123456789", expectedOk: true},
+		{text: "8 (912) 345-67-89", expected: "+79123456789", expectedOk: true},
+		{text: "79123456789", expected: "+79123456789", expectedOk: true},
+		{text: "916 123-45-67", expected: "+79161234567", expectedOk: true},
+		{text: "+44 20 7946 0958", expected: "+442079460958", expectedOk: true},
+		{text: "12345", expectedOk: false},
+		{text: "позвоните мне", expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			phone, ok := normalizePhone(tt.text)
+			if ok != tt.expectedOk || phone != tt.expected {
+				t.Errorf("Expected %q %v, got %q %v", tt.expected, tt.expectedOk, phone, ok)
+			}
+		})
+	}
+}
+
+func TestOrderDetailsChangeQuantity(t *testing.T) {
+	product := &Product{ID: "lavender", Name: "Лавандовое мыло", Price: 450}
+	details := &OrderDetails{}
+
+	details.changeQuantity(product, 1)
+	details.changeQuantity(product, 1)
+	if details.quantity("lavender") != 2 || details.total() != 900 {
+		t.Errorf("Expected 2 pieces for 900, got %d for %d", details.quantity("lavender"), details.total())
+	}
+
+	details.changeQuantity(product, -2)
+	if len(details.Items) != 0 {
+		t.Errorf("Expected the item to be removed, got %v", details.Items)
+	}
+
+	details.changeQuantity(product, -1)
+	if len(details.Items) != 0 {
+		t.Error("Expected removing a missing item to do nothing")
+	}
+}
+
+func TestOrderConversation(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/order"))
+	if text := lastSentText(t, api); !strings.Contains(text, "Корзина пуста") {
+		t.Errorf("Expected empty cart, got %s", text)
+	}
+	keyboard := api.callsTo("sendMessage")[0].Params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)
+	if len(keyboard) != 3 {
+		t.Errorf("Expected 2 products in stock and a control row, got %d rows", len(keyboard))
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:next"))
+	answers := api.callsTo("answerCallbackQuery")
+	if answers[len(answers)-1].Params["text"] != "Добавьте хотя бы один товар" {
+		t.Errorf("Expected an empty cart to be rejected, got %v", answers[len(answers)-1].Params)
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:add:lavender"))
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:add:lavender"))
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:add:rose"))
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:sub:rose"))
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:add:mint"))
+	edits := api.callsTo("editMessageText")
+	if text := edits[len(edits)-1].Params["text"].(string); !strings.Contains(text, "Лавандовое мыло × 2 — 900 ₽") || strings.Contains(text, "розы") {
+		t.Errorf("Expected only 2 lavender soaps in the cart, got %s", text)
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:next"))
+	app.handleTelegramUpdate(createTestPrivateMessage("Москва"))
+	if text := lastSentText(t, api); !strings.Contains(text, "кнопками") {
+		t.Errorf("Expected a hint to use the buttons, got %s", text)
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:delivery:1"))
+	app.handleTelegramUpdate(createTestPrivateMessage("Москва"))
+	phonePrompt := api.callsTo("sendMessage")
+	markup := phonePrompt[len(phonePrompt)-1].Params["reply_markup"].(map[string]any)
+	button := markup["keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if button["request_contact"] != true {
+		t.Errorf("Expected a request_contact button, got %v", markup)
+	}
+
+	app.handleTelegramUpdate(createTestPrivateMessage("не скажу"))
+	if text := lastSentText(t, api); !strings.Contains(text, "Не получилось распознать номер") {
+		t.Errorf("Expected the phone to be rejected, got %s", text)
+	}
+
+	contact := createTestPrivateMessage("")
+	contact.Message.Contact = &Contact{PhoneNumber: "79123456789", UserID: testCustomerID}
+	app.handleTelegramUpdate(contact)
+	if text := lastSentText(t, api); !strings.Contains(text, "Проверьте заказ") || !strings.Contains(text, "Телефон: +79123456789") {
+		t.Errorf("Expected order summary, got %s", text)
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:confirm"))
+
+	var order *Order
+	app.store.view(func(data *storeData) {
+		if len(data.Orders) == 1 {
+			order = data.Orders[0]
+		}
+		if len(data.OrderDrafts) != 0 {
+			t.Error("Expected the draft to be removed")
+		}
+	})
+	if order == nil {
+		t.Fatal("Expected 1 order")
+	}
+	if order.ID != 1 || order.Status != orderStatusNew || order.Delivery != "СДЭК" || order.City != "Москва" || order.total() != 900 {
+		t.Errorf("Unexpected order %+v", order)
+	}
+
+	var notification *recordedCall
+	for _, call := range api.callsTo("sendMessage") {
+		if call.Params["chat_id"] == float64(555) {
+			notification = &call
+		}
+	}
+	if notification == nil {
+		t.Fatal("Expected admins to be notified")
+	}
+	text := notification.Params["text"].(string)
+	for _, expected := range []string{"Заказ №1", "Jane", "Статус: Новый", "Итого: 900 ₽", "Доставка: СДЭК", "Город: Москва", "+79123456789"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected notification to contain %q, got %s", expected, text)
+		}
+	}
+}
+
+func TestOrderContactOfSomeoneElseIsIgnored(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	app.saveOrderDraft(testCustomerID, OrderDraft{Step: orderStepPhone}, time.Now())
+
+	contact := createTestPrivateMessage("")
+	contact.Message.Contact = &Contact{PhoneNumber: "79123456789", UserID: 999}
+	app.handleTelegramUpdate(contact)
+
+	if text := lastSentText(t, api); !strings.Contains(text, "Не получилось распознать номер") {
+		t.Errorf("Expected a shared contact to be rejected, got %s", text)
+	}
+}
+
+func TestOrderCommandInGroup(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+
+	app.handleTelegramUpdate(createTestCommand("/order"))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 reply, got %d", len(calls))
+	}
+	button := calls[0].Params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if button["url"] != "https://t.me/soapmama_bot?start=order" {
+		t.Errorf("Expected deep link to the bot, got %v", button["url"])
+	}
+	app.store.view(func(data *storeData) {
+		if len(data.OrderDrafts) != 0 {
+			t.Error("Expected no conversation in the group")
+		}
+	})
+}
+
+func TestStartOrderDeepLinkWithProduct(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/start order-rose"))
+
+	draft, ok := app.orderDraft(testCustomerID)
+	if !ok || draft.quantity("rose") != 1 {
+		t.Errorf("Expected a rose hydrolate in the cart, got %+v", draft)
+	}
+	if text := lastSentText(t, api); !strings.Contains(text, "Гидролат розы × 1") {
+		t.Errorf("Expected the cart in the message, got %s", text)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	app.handleTelegramUpdate(createTestPrivateMessage("/order"))
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/cancel"))
+
+	if _, ok := app.orderDraft(testCustomerID); ok {
+		t.Error("Expected the draft to be removed")
+	}
+	if text := lastSentText(t, api); text != "Оформление заказа отменено." {
+		t.Errorf("Expected cancellation reply, got %s", text)
+	}
+
+	app.handleTelegramUpdate(createTestOrderCallback(app, "order:add:lavender"))
+	answers := api.callsTo("answerCallbackQuery")
+	if answers[len(answers)-1].Params["text"] == nil {
+		t.Error("Expected old buttons to be answered with a notice")
+	}
+}
+
+func TestOrderDraftTimeout(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	now := time.Now()
+	app.saveOrderDraft(testCustomerID, OrderDraft{Step: orderStepCity}, now.Add(-time.Hour))
+	app.saveOrderDraft(444, OrderDraft{Step: orderStepCity}, now.Add(-time.Minute))
+
+	app.expireOrderDrafts(now)
+
+	if _, ok := app.orderDraft(testCustomerID); ok {
+		t.Error("Expected the idle draft to expire")
+	}
+	if _, ok := app.orderDraft(444); !ok {
+		t.Error("Expected the recent draft to stay")
+	}
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["chat_id"] != float64(testCustomerID) || calls[0].Params["text"] != orderExpiredNotice {
+		t.Errorf("Expected the expired notice to the idle user, got %v", calls)
+	}
+
+	written := watchStoreWrites(t, app.store)
+	app.expireOrderDrafts(now)
+	if written() {
+		t.Error("Expected the store not to be rewritten when nothing expired")
+	}
+}
+
+func TestOrderMessageAfterTimeout(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+	app.saveOrderDraft(testCustomerID, OrderDraft{Step: orderStepCity}, time.Now().Add(-time.Hour))
+
+	app.handleTelegramUpdate(createTestPrivateMessage("Москва"))
+
+	if _, ok := app.orderDraft(testCustomerID); ok {
+		t.Error("Expected the expired draft to be removed")
+	}
+	if text := lastSentText(t, api); text != orderExpiredNotice {
+		t.Errorf("Expected the expired notice, got %s", text)
+	}
+}
+
+func TestInlineOrderButtonUsesDeepLink(t *testing.T) {
+	app, api := newTestApp(t, ordersTestConfig)
+
+	app.handleInlineQuery(&InlineQuery{ID: "query", Query: "розы"})
+
+	results := api.callsTo("answerInlineQuery")[0].Params["results"].([]any)
+	button := results[0].(map[string]any)["reply_markup"].(map[string]any)["inline_keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if button["url"] != "https://t.me/soapmama_bot?start=order-rose" {
+		t.Errorf("Expected order deep link, got %v", button["url"])
+	}
+}
+
+func TestCreateOrderOnlyOnce(t *testing.T) {
+	app, _ := newTestApp(t, ordersTestConfig)
+	customer := User{ID: testCustomerID, FirstName: "Jane"}
+	now := time.Now()
+	app.saveOrderDraft(testCustomerID, OrderDraft{
+		Step:         orderStepConfirm,
+		OrderDetails: OrderDetails{Items: []OrderItem{{ProductID: "lavender", Name: "Лавандовое мыло", Price: 450, Quantity: 1}}},
+	}, now)
+
+	// Both taps on «Подтвердить» got past the draft check before either saved the order
+	first, err := app.createOrder(&customer, now)
+	if err != nil || first == nil {
+		t.Fatalf("Expected the order to be created, got %v, %v", first, err)
+	}
+	second, err := app.createOrder(&customer, now)
+	if err != nil || second != nil {
+		t.Errorf("Expected the second tap to find no draft, got %v, %v", second, err)
+	}
+	app.store.view(func(data *storeData) {
+		if len(data.Orders) != 1 {
+			t.Errorf("Expected 1 order, got %d", len(data.Orders))
+		}
+	})
+}
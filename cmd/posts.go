@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxPostTitleLength = 50
+	// postDraftTimeout is how long /schedule and /broadcast wait for the post
+	postDraftTimeout = time.Hour
+)
+
+// postButtonPattern matches the button lines that end a post: "Текст | https://example.com"
+var postButtonPattern = regexp.MustCompile(`^(.+?)\s*\|\s*(https?://\S+)$`)
+
+// Post is a message composed by an admin in private chat for /schedule and /broadcast
+type Post struct {
+	Text        string   `json:"text"`
+	PhotoFileID string   `json:"photo_file_id,omitempty"`
+	Buttons     []Button `json:"buttons,omitempty"`
+}
+
+// splitPostButtons cuts the trailing button lines off text
+func splitPostButtons(text string) (string, []Button) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	end := len(lines)
+	for end > 0 {
+		match := postButtonPattern.FindStringSubmatch(strings.TrimSpace(lines[end-1]))
+		if match == nil || validateUrl("button", match[2]) != nil {
+			break
+		}
+		end--
+	}
+
+	var buttons []Button
+	for _, line := range lines[end:] {
+		match := postButtonPattern.FindStringSubmatch(strings.TrimSpace(line))
+		buttons = append(buttons, Button{Text: match[1], Url: match[2]})
+	}
+	return strings.TrimSpace(strings.Join(lines[:end], "\n")), buttons
+}
+
+func (p *Post) title() string {
+	title, _, _ := strings.Cut(p.Text, "\n")
+	if runes := []rune(title); len(runes) > maxPostTitleLength {
+		title = string(runes[:maxPostTitleLength]) + "…"
+	}
+	if p.PhotoFileID != "" {
+		title = "[фото] " + title
+	}
+	return title
+}
+
+func postParams(post *Post, chatID int64, threadID int64) map[string]any {
+	params := map[string]any{
+		"chat_id":    chatID,
+		"text":       post.Text,
+		"parse_mode": "HTML",
+	}
+	if len(post.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(post.Buttons)
+	}
+	setMessageThreadID(params, threadID)
+	return params
+}
+
+// sendPost sends through the same path as the welcome message, a photo carries the text as its caption
+func (app *App) sendPost(post *Post, chatID int64, threadID int64) error {
+	params := postParams(post, chatID, threadID)
+	if post.PhotoFileID != "" {
+		return app.sendWelcomePhoto(params, WelcomeMedia{FileID: post.PhotoFileID})
+	}
+	_, err := app.telegram.sendMessage(params)
+	return err
+}
+
+// composePost reads a post from the admin's message and sends it back as a preview.
+// The preview is exactly what readers will get, so markup Telegram can't parse is caught here.
+func (app *App) composePost(message *Message) (Post, bool) {
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	body, buttons := splitPostButtons(text)
+	post := Post{Text: body, PhotoFileID: largestPhotoFileID(message.Photo), Buttons: buttons}
+	if post.Text == "" && post.PhotoFileID == "" {
+		app.replyText(message, "Пришлите текст или фото с подписью.")
+		return post, false
+	}
+
+	if err := app.sendPost(&post, message.Chat.ID, 0); err != nil {
+		slog.Warn("Error sending post preview", "user_id", message.From.ID, "error", err)
+		app.replyText(message, fmt.Sprintf("Telegram не принял сообщение: %s\nИсправьте его и пришлите ещё раз.", err))
+		return post, false
+	}
+	return post, true
+}
+
+func isPostDraftExpired(startedAt time.Time, now time.Time) bool {
+	return now.Sub(startedAt) > postDraftTimeout
+}
+
+// expirePostDrafts drops the /schedule and /broadcast drafts nobody finished,
+// so they stop catching the admins' private messages
+func (app *App) expirePostDrafts(now time.Time) {
+	// Most ticks find nothing to expire, so the store is only rewritten when there is something
+	var found bool
+	app.store.view(func(data *storeData) {
+		for _, draft := range data.AnnouncementDrafts {
+			found = found || isPostDraftExpired(draft.StartedAt, now)
+		}
+		for _, startedAt := range data.BroadcastDrafts {
+			found = found || isPostDraftExpired(startedAt, now)
+		}
+	})
+	if !found {
+		return
+	}
+
+	err := app.store.update(func(data *storeData) {
+		for userID, draft := range data.AnnouncementDrafts {
+			if isPostDraftExpired(draft.StartedAt, now) {
+				delete(data.AnnouncementDrafts, userID)
+			}
+		}
+		for userID, startedAt := range data.BroadcastDrafts {
+			if isPostDraftExpired(startedAt, now) {
+				delete(data.BroadcastDrafts, userID)
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error expiring post drafts", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSplitPostButtons(t *testing.T) {
+	tests := []struct {
+		name            string
+		text            string
+		expectedText    string
+		expectedButtons []Button
+	}{
+		{
+			name:         "no buttons",
+			text:         "Новинки недели",
+			expectedText: "Новинки недели",
+		},
+		{
+			name:         "buttons at the end",
+			text:         "Новинки недели\n\nКаталог | https://example.com/catalog\nЦены|https://example.com/prices",
+			expectedText: "Новинки недели",
+			expectedButtons: []Button{
+				{Text: "Каталог", Url: "https://example.com/catalog"},
+				{Text: "Цены", Url: "https://example.com/prices"},
+			},
+		},
+		{
+			name:         "button line in the middle",
+			text:         "Каталог | https://example.com/catalog\nНовинки недели",
+			expectedText: "Каталог | https://example.com/catalog\nНовинки недели",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, buttons := splitPostButtons(tt.text)
+			if text != tt.expectedText {
+				t.Errorf("Expected text %q, got %q", tt.expectedText, text)
+			}
+			if len(buttons) != len(tt.expectedButtons) {
+				t.Fatalf("Expected %v, got %v", tt.expectedButtons, buttons)
+			}
+			for i := range buttons {
+				if buttons[i] != tt.expectedButtons[i] {
+					t.Errorf("Expected %v, got %v", tt.expectedButtons[i], buttons[i])
+				}
+			}
+		})
+	}
+}
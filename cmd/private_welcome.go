@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+)
+
+const (
+	welcomeStartPayload = "welcome"
+	giftButtonText      = "Нажмите, чтобы получить подарок"
+	// privateWelcomeButtonText is used when no welcome.gift is configured
+	privateWelcomeButtonText = "Продолжить в личных сообщениях"
+)
+
+// privateWelcomeParams is the welcome of the General topic addressed to a single member
+func (app *App) privateWelcomeParams(user User) map[string]any {
+	params := app.newMembersMessageParams(app.defaultWelcomeTopic(), []User{user})
+	params["chat_id"] = user.ID
+	delete(params, "message_thread_id")
+	return params
+}
+
+// sendPrivateWelcome sends the welcome and then welcome.gift, if there is one
+func (app *App) sendPrivateWelcome(user User) error {
+	if err := app.sendWelcome(app.privateWelcomeParams(user)); err != nil {
+		return err
+	}
+	if app.config.Welcome.Gift == "" {
+		return nil
+	}
+	links := app.links()
+	_, err := app.telegram.sendMessage(map[string]any{
+		"chat_id":    user.ID,
+		"text":       renderLinks(app.config.Welcome.Gift, &links),
+		"parse_mode": "HTML",
+	})
+	return err
+}
+
+// sendPrivateWelcomes sends the welcome to newcomers who have started the bot and returns
+// the rest, Telegram doesn't let bots write first
+func (app *App) sendPrivateWelcomes(newcomers []User) []User {
+	var unreachable []User
+	for _, user := range newcomers {
+		err := app.sendPrivateWelcome(user)
+		if err == nil {
+			slog.Info("Sent private welcome", "user_id", user.ID)
+			continue
+		}
+		var apiErr *ApiError
+		if errors.As(err, &apiErr) && apiErr.Code == 403 {
+			slog.Info("Can't send private welcome", "user_id", user.ID, "error", apiErr.Description)
+		} else {
+			slog.Error("Error sending private welcome", "user_id", user.ID, "error", err)
+		}
+		unreachable = append(unreachable, user)
+	}
+	return unreachable
+}
+
+// addGiftButton puts the deep link to the private welcome above the buttons of a group welcome,
+// it promises a gift only when welcome.gift is set
+func (app *App) addGiftButton(params map[string]any) {
+	markup := params["reply_markup"].(map[string]any)
+	keyboard, _ := markup["inline_keyboard"].([][]map[string]string)
+	text := privateWelcomeButtonText
+	if app.config.Welcome.Gift != "" {
+		text = giftButtonText
+	}
+	gift := []map[string]string{{"text": text, "url": app.deepLink(welcomeStartPayload)}}
+	markup["inline_keyboard"] = append([][]map[string]string{gift}, keyboard...)
+}
+
+func (app *App) handleWelcomeStart(message *Message) {
+	if err := app.sendPrivateWelcome(message.From); err != nil {
+		slog.Error("Error sending private welcome", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось отправить приветствие, попробуйте ещё раз.")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func privateWelcomeTestConfig(config *Config) {
+	config.ThreadID = 7
+	config.Welcome = Welcome{Private: true, Gift: "Промокод WELCOME10, цены: {prices}"}
+}
+
+func TestPrivateWelcome(t *testing.T) {
+	app, api := newTestApp(t, privateWelcomeTestConfig)
+	stranger := User{ID: 444555666, FirstName: "John"}
+	api.setChatResponse("sendMessage", stranger.ID, `{"ok": false, "error_code": 403, "description": "Forbidden: bot can't initiate conversation with a user"}`)
+
+	app.handleTelegramUpdate(createTestMembersJoin(testHuman, stranger))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 4 {
+		t.Fatalf("Expected the welcome and the gift to Jane, an attempt for John and a group welcome, got %d messages", len(calls))
+	}
+	private := calls[0].Params
+	if private["chat_id"] != float64(testHuman.ID) || private["message_thread_id"] != nil || !strings.Contains(private["text"].(string), "@janesmith") {
+		t.Errorf("Expected the welcome in Jane's private chat, got %v", private)
+	}
+
+	if gift := calls[1].Params; gift["chat_id"] != float64(testHuman.ID) || gift["text"] != "Промокод WELCOME10, цены: https://example.com/prices" {
+		t.Errorf("Expected the gift after the private welcome, got %v", gift)
+	}
+
+	group := calls[3].Params
+	text := group["text"].(string)
+	if group["chat_id"] != float64(123456789) || !strings.Contains(text, "John") || strings.Contains(text, "janesmith") {
+		t.Errorf("Expected the group welcome only for John, got %v", group)
+	}
+	gift := group["reply_markup"].(map[string]any)["inline_keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if gift["text"] != giftButtonText || gift["url"] != "https://t.me/soapmama_bot?start=welcome" {
+		t.Errorf("Expected the gift button first, got %v", gift)
+	}
+}
+
+func TestPrivateWelcomeFromDeepLink(t *testing.T) {
+	app, api := newTestApp(t, privateWelcomeTestConfig)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/start welcome"))
+
+	calls := api.callsTo("sendMessage")
+	params := calls[0].Params
+	if params["chat_id"] != float64(testCustomerID) || !strings.HasPrefix(params["text"].(string), "Привет, ") {
+		t.Errorf("Expected the welcome in private chat, got %v", params)
+	}
+	if len(calls) != 2 || !strings.HasPrefix(calls[1].Params["text"].(string), "Промокод WELCOME10") {
+		t.Errorf("Expected the gift after the welcome, got %v", calls)
+	}
+	buttons := params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)
+	for _, row := range buttons {
+		if button := row.([]any)[0].(map[string]any); button["text"] == giftButtonText {
+			t.Error("Expected no gift button in the private welcome")
+		}
+	}
+}
+
+func TestPrivateWelcomeButtonWithoutGift(t *testing.T) {
+	app, _ := newTestApp(t, privateWelcomeTestConfig)
+	app.config.Welcome.Gift = ""
+	params := app.newMembersMessageParams(app.defaultWelcomeTopic(), []User{testHuman})
+
+	app.addGiftButton(params)
+
+	button := params["reply_markup"].(map[string]any)["inline_keyboard"].([][]map[string]string)[0][0]
+	if button["text"] != privateWelcomeButtonText {
+		t.Errorf("Expected no gift to be promised, got %v", button)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	inlineResultsPerPage = 20
+	inlineCacheSeconds   = 300
+	minStemLength        = 3
+)
+
+// russianEndings are stripped from search words so "лаванда" finds "лавандовое",
+// longer endings come first
+var russianEndings = []string{
+	"ами", "ями", "ого", "его", "ому", "ему", "ыми", "ими",
+	"ой", "ей", "ый", "ий", "ая", "яя", "ое", "ее", "ые", "ие", "ов", "ев", "ам", "ям", "ах", "ях", "ом", "ем", "ую", "юю",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+func searchTokens(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func stemRussian(word string) string {
+	length := len([]rune(word))
+	for _, ending := range russianEndings {
+		if strings.HasSuffix(word, ending) && length-len([]rune(ending)) >= minStemLength {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
+
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// maxTypos is how many edits a search word of length runes may differ by
+func maxTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matchToken scores how well a query token matches a product word, 0 means no match.
+// Exact prefixes score highest, then matching stems, then stems with typos.
+func matchToken(token string, word string) int {
+	if strings.HasPrefix(word, token) {
+		return 3
+	}
+	stem := stemRussian(token)
+	if strings.HasPrefix(word, stem) {
+		return 2
+	}
+
+	stemRunes := []rune(stem)
+	typos := maxTypos(len(stemRunes))
+	if typos == 0 {
+		return 0
+	}
+	// Compare against word prefixes around the stem length so a typo in "лавнда" still finds "лавандовое"
+	wordRunes := []rune(word)
+	for length := len(stemRunes) - typos; length <= len(stemRunes)+typos; length++ {
+		if length < minStemLength || length > len(wordRunes) {
+			continue
+		}
+		if levenshtein(stemRunes, wordRunes[:length]) <= typos {
+			return 1
+		}
+	}
+	return 0
+}
+
+// searchProducts returns the products whose name or ingredients match every word of query,
+// best matches first. An empty query returns the whole catalog.
+func searchProducts(products []Product, query string) []Product {
+	tokens := searchTokens(query)
+	if len(tokens) == 0 {
+		return products
+	}
+
+	type match struct {
+		product Product
+		score   int
+	}
+	var matches []match
+	for _, product := range products {
+		words := searchTokens(product.Name + " " + strings.Join(product.Ingredients, " "))
+		total := 0
+		for _, token := range tokens {
+			best := 0
+			for _, word := range words {
+				best = max(best, matchToken(token, word))
+			}
+			if best == 0 {
+				total = 0
+				break
+			}
+			total += best
+		}
+		if total > 0 {
+			matches = append(matches, match{product: product, score: total})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int { return b.score - a.score })
+	result := make([]Product, len(matches))
+	for i, match := range matches {
+		result[i] = match.product
+	}
+	return result
+}
+
+func inlineProductResult(product *Product, orderUrl string) map[string]any {
+	card := createProductCard(product)
+	description := formatPrice(product.Price)
+	if !product.InStock {
+		description += ", нет в наличии"
+	}
+
+	var result map[string]any
+	if product.Photo != "" {
+		result = map[string]any{
+			"type":          "photo",
+			"photo_url":     product.Photo,
+			"thumbnail_url": product.Photo,
+			"title":         product.Name,
+			"description":   description,
+			"caption":       card,
+			"parse_mode":    "HTML",
+		}
+	} else {
+		result = map[string]any{
+			"type":        "article",
+			"title":       product.Name,
+			"description": description,
+			"input_message_content": map[string]any{
+				"message_text": card,
+				"parse_mode":   "HTML",
+			},
+		}
+	}
+	result["id"] = product.ID
+	result["reply_markup"] = map[string]any{
+		"inline_keyboard": [][]map[string]string{{{"text": "Заказать", "url": orderUrl}}},
+	}
+	return result
+}
+
+func (app *App) handleInlineQuery(query *InlineQuery) {
+	matches := searchProducts(app.config.Products, query.Query)
+	offset, _ := strconv.Atoi(query.Offset)
+	start, end := min(max(offset, 0), len(matches)), min(max(offset, 0)+inlineResultsPerPage, len(matches))
+
+	results := make([]map[string]any, 0, end-start)
+	for i := start; i < end; i++ {
+		orderUrl := app.orderDeepLink(orderStartPayload + "-" + matches[i].ID)
+		if orderUrl == "" {
+			orderUrl = app.links().Prices
+		}
+		results = append(results, inlineProductResult(&matches[i], orderUrl))
+	}
+
+	params := map[string]any{
+		"inline_query_id": query.ID,
+		"results":         results,
+		"cache_time":      inlineCacheSeconds,
+	}
+	if end < len(matches) {
+		params["next_offset"] = strconv.Itoa(end)
+	}
+	if err := app.telegram.answerInlineQuery(params); err != nil {
+		slog.Error("Error answering inline query", "query", query.Query, "error", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testSearchProducts() []Product {
+	return []Product{
+		{ID: "lavender", Name: "Лавандовое мыло", Category: "Мыло", Price: 450, Ingredients: []string{"оливковое масло", "эфирное масло лаванды"}, Photo: "https://example.com/lavender.jpg", InStock: true},
+		{ID: "honey", Name: "Медовое мыло", Category: "Мыло", Price: 400, Ingredients: []string{"мёд", "овсяные хлопья"}, InStock: true},
+		{ID: "rose", Name: "Гидролат розы", Category: "Гидролаты", Price: 600, Ingredients: []string{"лепестки розы"}},
+		{ID: "ubtan", Name: "Убтан с лавандой", Category: "Убтаны", Price: 350, Ingredients: []string{"нутовая мука"}, InStock: true},
+	}
+}
+
+func productIDs(products []Product) string {
+	var ids []string
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestStemRussian(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{word: "лаванда", expected: "лаванд"},
+		{word: "лавандовое", expected: "лавандов"},
+		{word: "розы", expected: "роз"},
+		{word: "мыло", expected: "мыл"},
+		{word: "мёд", expected: "мёд"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if result := stemRussian(tt.word); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "лаванд", b: "лаванд", expected: 0},
+		{a: "лавнд", b: "лаванд", expected: 1},
+		{a: "мыло", b: "мило", expected: 1},
+		{a: "", b: "роза", expected: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if result := levenshtein([]rune(tt.a), []rune(tt.b)); result != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "empty query returns everything", query: "  ", expected: "lavender,honey,rose,ubtan"},
+		{name: "word form", query: "лаванда", expected: "lavender,ubtan"},
+		{name: "case insensitive", query: "ЛАВАНДА", expected: "lavender,ubtan"},
+		{name: "typo", query: "лавнда", expected: "lavender,ubtan"},
+		{name: "ё matches е", query: "мед", expected: "honey"},
+		{name: "ingredient", query: "нутовая", expected: "ubtan"},
+		{name: "every word must match", query: "мыло лаванда", expected: "lavender"},
+		{name: "prefix while typing", query: "гидр", expected: "rose"},
+		{name: "no match", query: "шампунь", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := productIDs(searchProducts(testSearchProducts(), tt.query)); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSearchProductsRanksCloserMatchesFirst(t *testing.T) {
+	products := []Product{
+		{ID: "typo", Name: "Рамашка"},
+		{ID: "stem", Name: "Ромашковое мыло"},
+		{ID: "exact", Name: "Ромашка"},
+	}
+	if result := productIDs(searchProducts(products, "ромашка")); result != "exact,stem,typo" {
+		t.Errorf("Expected exact,stem,typo, got %s", result)
+	}
+}
+
+func TestHandleInlineQuery(t *testing.T) {
+	app, api := newTestApp(t, nil)
+	app.config.Products = testSearchProducts()
+
+	app.handleTelegramUpdate(&Update{InlineQuery: &InlineQuery{ID: "query", Query: "лаванда"}})
+
+	calls := api.callsTo("answerInlineQuery")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 answer, got %d", len(calls))
+	}
+	params := calls[0].Params
+	if params["inline_query_id"] != "query" {
+		t.Errorf("Expected inline_query_id query, got %v", params["inline_query_id"])
+	}
+	if _, ok := params["next_offset"]; ok {
+		t.Error("Expected no next_offset for a single page")
+	}
+	results := params["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	photo := results[0].(map[string]any)
+	if photo["type"] != "photo" || photo["photo_url"] != "https://example.com/lavender.jpg" {
+		t.Errorf("Expected photo result, got %v", photo)
+	}
+	if !strings.Contains(photo["caption"].(string), "450 ₽") {
+		t.Errorf("Expected price in caption, got %v", photo["caption"])
+	}
+	button := photo["reply_markup"].(map[string]any)["inline_keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if button["text"] != "Заказать" || button["url"] != "https://example.com/prices" {
+		t.Errorf("Expected order button, got %v", button)
+	}
+
+	article := results[1].(map[string]any)
+	if article["type"] != "article" || article["description"] != "350 ₽" {
+		t.Errorf("Expected article result with price, got %v", article)
+	}
+}
+
+func TestHandleInlineQueryPagination(t *testing.T) {
+	app, api := newTestApp(t, nil)
+	for i := range inlineResultsPerPage + 5 {
+		app.config.Products = append(app.config.Products, Product{ID: fmt.Sprintf("soap%d", i), Name: "Мыло"})
+	}
+
+	app.handleInlineQuery(&InlineQuery{ID: "first", Query: "мыло"})
+	app.handleInlineQuery(&InlineQuery{ID: "second", Query: "мыло", Offset: "20"})
+
+	calls := api.callsTo("answerInlineQuery")
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 answers, got %d", len(calls))
+	}
+	if len(calls[0].Params["results"].([]any)) != inlineResultsPerPage || calls[0].Params["next_offset"] != "20" {
+		t.Errorf("Expected a full first page with next_offset 20, got %v", calls[0].Params["next_offset"])
+	}
+	if len(calls[1].Params["results"].([]any)) != 5 || calls[1].Params["next_offset"] != nil {
+		t.Errorf("Expected 5 results on the last page, got %d", len(calls[1].Params["results"].([]any)))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	// Timezones of [[schedule]] must load in containers without tzdata
+	_ "time/tzdata"
+)
+
+const (
+	schedulePeriod = 30 * time.Second
+	// scheduleMissedLimit is how late a post may still go out after the bot was down at its time,
+	// "orders close on Friday" makes no sense on Saturday
+	scheduleMissedLimit = time.Hour
+)
+
+type scheduledJob struct {
+	post     ScheduledPost
+	schedule *CronSchedule
+	location *time.Location
+}
+
+func newScheduledJobs(posts []ScheduledPost) []scheduledJob {
+	var jobs []scheduledJob
+	for _, post := range posts {
+		schedule, err := parseCron(post.Cron)
+		if err != nil {
+			slog.Error("Invalid schedule, it won't run", "id", post.ID, "error", err)
+			continue
+		}
+		location, err := time.LoadLocation(post.Timezone)
+		if err != nil {
+			slog.Error("Invalid schedule timezone, it won't run", "id", post.ID, "error", err)
+			continue
+		}
+		jobs = append(jobs, scheduledJob{post: post, schedule: schedule, location: location})
+	}
+	return jobs
+}
+
+// latestRun returns the last time the job was due after lastRun and not later than now,
+// it's zero when the job is not due
+func (j *scheduledJob) latestRun(lastRun time.Time, now time.Time) time.Time {
+	var latest time.Time
+	for due := j.schedule.next(lastRun.In(j.location)); !due.IsZero() && !due.After(now); due = j.schedule.next(due) {
+		latest = due
+	}
+	return latest
+}
+
+func (app *App) scheduledPostParams(post *ScheduledPost) map[string]any {
+	chatID, threadID := post.ChatID, post.ThreadID
+	if chatID == 0 {
+		chatID = app.config.ChatID
+		if threadID == 0 {
+			threadID = app.config.ThreadID
+		}
+	}
+	links := app.links()
+	params := map[string]any{
+		"chat_id":    chatID,
+		"text":       renderLinks(post.Template, &links),
+		"parse_mode": "HTML",
+	}
+	if len(post.Buttons) > 0 {
+		params["reply_markup"] = createCustomButtonsMarkup(app.trackButtons(post.Buttons, chatID))
+	}
+	setMessageThreadID(params, threadID)
+	return params
+}
+
+// claimScheduledRun reports the run of job that is due at now, if any, and records now as its last run.
+// The run is saved before the post is sent, so a restart in between skips it rather than posting twice.
+func (app *App) claimScheduledRun(job *scheduledJob, now time.Time) (time.Time, error) {
+	var lastRun time.Time
+	var ok bool
+	app.store.view(func(data *storeData) {
+		lastRun, ok = data.ScheduleRuns[job.post.ID]
+	})
+
+	var due time.Time
+	if ok {
+		due = job.latestRun(lastRun, now)
+		if due.IsZero() {
+			return due, nil
+		}
+	}
+	// A new schedule starts counting from now instead of posting right away
+	err := app.store.update(func(data *storeData) {
+		data.ScheduleRuns[job.post.ID] = now
+	})
+	return due, err
+}
+
+func (app *App) runSchedules(now time.Time) {
+	for i := range app.schedule {
+		job := &app.schedule[i]
+		due, err := app.claimScheduledRun(job, now)
+		if err != nil {
+			slog.Error("Error saving scheduled run", "id", job.post.ID, "error", err)
+			continue
+		}
+		if due.IsZero() {
+			continue
+		}
+		if now.Sub(due) > scheduleMissedLimit {
+			slog.Warn("Skipping missed scheduled post", "id", job.post.ID, "due", due)
+			continue
+		}
+
+		slog.Info("Sending scheduled post", "id", job.post.ID, "due", due)
+		if _, err := app.telegram.sendMessage(app.scheduledPostParams(&job.post)); err != nil {
+			slog.Error("Error sending scheduled post", "id", job.post.ID, "error", err)
+		}
+	}
+}
+
+// startScheduler runs [[schedule]] posts, the announcements admins scheduled with /schedule
+// and the [onboarding] steps
+func (app *App) startScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(schedulePeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				app.runSchedules(now)
+				app.runAnnouncements(now)
+				app.runOnboarding(now)
+				app.flushClicks(now)
+				app.expirePostDrafts(now)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func scheduleTestConfig(config *Config) {
+	config.ThreadID = 7
+	config.Schedule = []ScheduledPost{
+		{ID: "orders", Cron: "0 10 * * 3", Timezone: "Europe/Moscow", Template: "Приём заказов до пятницы: {prices}"},
+		{ID: "news", Cron: "0 12 * * 1", Timezone: "Europe/Moscow", ChatID: 555, Template: "Новинки недели"},
+	}
+}
+
+func TestRunSchedules(t *testing.T) {
+	store := newMemoryStore()
+	app, api := newTestApp(t, scheduleTestConfig)
+	app.store = store
+	// Wednesday 09:00 in Moscow
+	start := time.Date(2026, 3, 4, 6, 0, 0, 0, time.UTC)
+
+	app.runSchedules(start)
+	app.runSchedules(start.Add(30 * time.Minute))
+	if calls := api.callsTo("sendMessage"); len(calls) != 0 {
+		t.Fatalf("Expected nothing before 10:00, got %v", calls)
+	}
+
+	app.runSchedules(start.Add(time.Hour))
+	app.runSchedules(start.Add(time.Hour + 30*time.Second))
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 post, got %d", len(calls))
+	}
+	params := calls[0].Params
+	if params["chat_id"] != float64(123456789) || params["message_thread_id"] != float64(7) || params["text"] != "Приём заказов до пятницы: https://example.com/prices" {
+		t.Errorf("Unexpected post %v", params)
+	}
+
+	// A restart with the same store doesn't post again
+	restarted, restartedApi := newTestApp(t, scheduleTestConfig)
+	restarted.store = store
+	restarted.runSchedules(start.Add(time.Hour + time.Minute))
+	if calls := restartedApi.callsTo("sendMessage"); len(calls) != 0 {
+		t.Errorf("Expected no repeated post after restart, got %v", calls)
+	}
+}
+
+func TestRunSchedulesAfterDowntime(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected int
+	}{
+		// Wednesday 10:20 and 13:00 in Moscow
+		{name: "shortly after", now: time.Date(2026, 3, 4, 7, 20, 0, 0, time.UTC), expected: 1},
+		{name: "too late", now: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC), expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			store.data.ScheduleRuns["orders"] = time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+			app, api := newTestApp(t, scheduleTestConfig)
+			app.store = store
+
+			app.runSchedules(tt.now)
+
+			if calls := api.callsTo("sendMessage"); len(calls) != tt.expected {
+				t.Errorf("Expected %d posts, got %d", tt.expected, len(calls))
+			}
+			if !store.data.ScheduleRuns["orders"].Equal(tt.now) {
+				t.Errorf("Expected the run to be recorded, got %v", store.data.ScheduleRuns["orders"])
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type MemberRecord struct {
+	FirstJoinedAt time.Time `json:"first_joined_at"`
+	LastJoinedAt  time.Time `json:"last_joined_at"`
+	Joins         int       `json:"joins"`
+}
+
+type storeData struct {
+	Members      map[int64]*MemberRecord `json:"members"`
+	MediaFileIDs map[string]string       `json:"media_file_ids"`
+	ButtonClicks []ButtonClick           `json:"button_clicks"`
+	// ButtonClickTotals counts every click by button id, ButtonClicks only keeps the latest ones
+	ButtonClickTotals map[string]int `json:"button_click_totals"`
+	// TelegraphPages maps content/*.md file names to the pages published from them
+	TelegraphPages map[string]PublishedPage `json:"telegraph_pages"`
+	// OrderDrafts holds the /order conversations in progress by user id
+	OrderDrafts map[int64]*OrderDraft `json:"order_drafts"`
+	Orders      []*Order              `json:"orders"`
+	LastOrderID int64                 `json:"last_order_id"`
+	// TrackingPrompts maps admin chat messages asking for a tracking number to their order id
+	TrackingPrompts map[int64]int64 `json:"tracking_prompts"`
+	// ScheduleRuns holds the last run of every [[schedule]] post by id
+	ScheduleRuns map[string]time.Time `json:"schedule_runs"`
+	// AnnouncementDrafts holds the /schedule post each admin is composing by user id
+	AnnouncementDrafts map[int64]*AnnouncementDraft `json:"announcement_drafts"`
+	Announcements      []*Announcement              `json:"announcements"`
+	LastAnnouncementID int64                        `json:"last_announcement_id"`
+	Subscribers        map[int64]*Subscriber        `json:"subscribers"`
+	// BroadcastDrafts holds when each admin started composing a /broadcast by user id
+	BroadcastDrafts map[int64]time.Time `json:"broadcast_drafts"`
+	Broadcasts      []*Broadcast        `json:"broadcasts"`
+	LastBroadcastID int64               `json:"last_broadcast_id"`
+	// Onboarding holds the progress of every user through [onboarding] by user id
+	Onboarding map[int64]*OnboardingProgress `json:"onboarding"`
+	// CampaignStarts counts /start from every campaign link, CampaignUsers holds the first campaign of each user
+	CampaignStarts   map[string]int          `json:"campaign_starts"`
+	CampaignUsers    map[int64]*CampaignUser `json:"campaign_users"`
+	InviteLinks      []*InviteLink           `json:"invite_links"`
+	LastInviteLinkID int64                   `json:"last_invite_link_id"`
+	// JoinRequests holds the join requests waiting for an answer or a review by user id
+	JoinRequests map[int64]*PendingJoinRequest `json:"join_requests"`
+}
+
+// Store keeps the bot state in a single JSON file that is rewritten on every update.
+// An empty path keeps the state in memory only.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	data storeData
+}
+
+func newStoreData() storeData {
+	return storeData{
+		Members:            make(map[int64]*MemberRecord),
+		MediaFileIDs:       make(map[string]string),
+		ButtonClickTotals:  make(map[string]int),
+		TelegraphPages:     make(map[string]PublishedPage),
+		OrderDrafts:        make(map[int64]*OrderDraft),
+		TrackingPrompts:    make(map[int64]int64),
+		ScheduleRuns:       make(map[string]time.Time),
+		AnnouncementDrafts: make(map[int64]*AnnouncementDraft),
+		Subscribers:        make(map[int64]*Subscriber),
+		BroadcastDrafts:    make(map[int64]time.Time),
+		Onboarding:         make(map[int64]*OnboardingProgress),
+		CampaignStarts:     make(map[string]int),
+		CampaignUsers:      make(map[int64]*CampaignUser),
+		JoinRequests:       make(map[int64]*PendingJoinRequest),
+	}
+}
+
+func newMemoryStore() *Store {
+	return &Store{data: newStoreData()}
+}
+
+func openStore(path string) (*Store, error) {
+	store := &Store{path: path, data: newStoreData()}
+	if path == "" {
+		return store, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data storeData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+	data.fillDefaults()
+	store.data = data
+	return store, nil
+}
+
+// fillDefaults initializes collections missing from files written by older versions
+func (d *storeData) fillDefaults() {
+	defaults := newStoreData()
+	if d.Members == nil {
+		d.Members = defaults.Members
+	}
+	if d.MediaFileIDs == nil {
+		d.MediaFileIDs = defaults.MediaFileIDs
+	}
+	if d.ButtonClickTotals == nil {
+		// Older versions only kept the capped history, count what is left of it
+		d.ButtonClickTotals = countClicks(d.ButtonClicks)
+	}
+	if d.TelegraphPages == nil {
+		d.TelegraphPages = defaults.TelegraphPages
+	}
+	if d.OrderDrafts == nil {
+		d.OrderDrafts = defaults.OrderDrafts
+	}
+	if d.TrackingPrompts == nil {
+		d.TrackingPrompts = defaults.TrackingPrompts
+	}
+	if d.ScheduleRuns == nil {
+		d.ScheduleRuns = defaults.ScheduleRuns
+	}
+	if d.AnnouncementDrafts == nil {
+		d.AnnouncementDrafts = defaults.AnnouncementDrafts
+	}
+	if d.Subscribers == nil {
+		d.Subscribers = defaults.Subscribers
+	}
+	if d.BroadcastDrafts == nil {
+		d.BroadcastDrafts = defaults.BroadcastDrafts
+	}
+	if d.Onboarding == nil {
+		d.Onboarding = defaults.Onboarding
+	}
+	if d.CampaignStarts == nil {
+		d.CampaignStarts = defaults.CampaignStarts
+	}
+	if d.CampaignUsers == nil {
+		d.CampaignUsers = defaults.CampaignUsers
+	}
+	if d.JoinRequests == nil {
+		d.JoinRequests = defaults.JoinRequests
+	}
+}
+
+func (s *Store) view(fn func(data *storeData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+}
+
+func (s *Store) update(fn func(data *storeData)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.data)
+	return s.save()
+}
+
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
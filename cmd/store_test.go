@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// watchStoreWrites points store at a file, the returned func reports whether it was written since the last call
+func watchStoreWrites(t *testing.T, store *Store) func() bool {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store.path = path
+	return func() bool {
+		_, err := os.Stat(path)
+		os.Remove(path)
+		return err == nil
+	}
+}
+
+func TestOpenStoreMissingFile(t *testing.T) {
+	store, err := openStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	store.view(func(data *storeData) {
+		if data.Members == nil {
+			t.Error("Expected members to be initialized")
+		}
+	})
+}
+
+func TestStorePersistsUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "store.json")
+	joinedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = store.update(func(data *storeData) {
+		data.Members[111222333] = &MemberRecord{FirstJoinedAt: joinedAt, LastJoinedAt: joinedAt, Joins: 1}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reopened, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	reopened.view(func(data *storeData) {
+		record := data.Members[111222333]
+		if record == nil {
+			t.Fatal("Expected member record to be persisted")
+		}
+		if !record.LastJoinedAt.Equal(joinedAt) {
+			t.Errorf("Expected %v, got %v", joinedAt, record.LastJoinedAt)
+		}
+	})
+}
+
+func TestOpenStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openStore(path); err == nil {
+		t.Error("Expected error for invalid store file")
+	}
+}
+
+func TestOpenStoreFillsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte(`{"members": null, "media_file_ids": null}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.view(func(data *storeData) {
+		if data.Members == nil || data.MediaFileIDs == nil || data.Subscribers == nil {
+			t.Error("Expected missing collections to be initialized")
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := newMemoryStore()
+
+	err := store.update(func(data *storeData) {
+		data.Members[1] = &MemberRecord{Joins: 1}
+	})
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestOpenStoreCountsClickTotalsFromHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	content := `{"button_clicks": [{"button": "soap"}, {"button": "soap"}, {"button": "prices"}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.view(func(data *storeData) {
+		if data.ButtonClickTotals["soap"] != 2 || data.ButtonClickTotals["prices"] != 1 {
+			t.Errorf("Expected totals from the stored clicks, got %v", data.ButtonClickTotals)
+		}
+	})
+}
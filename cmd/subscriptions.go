@@ -0,0 +1,433 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	subscribeStartPayload   = "subscribe"
+	broadcastCallbackPrefix = "broadcast"
+
+	broadcastStatusDraft     = "draft"
+	broadcastStatusSending   = "sending"
+	broadcastStatusDone      = "done"
+	broadcastStatusCancelled = "cancelled"
+
+	deliveryStatusPending = "pending"
+	deliveryStatusSent    = "sent"
+	deliveryStatusBlocked = "blocked"
+	deliveryStatusFailed  = "failed"
+
+	maxBroadcastRate = 30
+	// maxDeliveryAttempts bounds the retries of a message that hit flood control
+	maxDeliveryAttempts = 3
+	// deliverySavePeriod is how often the deliveries of a running broadcast are saved
+	deliverySavePeriod = time.Second
+)
+
+type Subscriber struct {
+	User         User      `json:"user"`
+	SubscribedAt time.Time `json:"subscribed_at"`
+}
+
+type Delivery struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Broadcast is a post sent by an admin to every subscriber in private chat
+type Broadcast struct {
+	ID int64 `json:"id"`
+	Post
+	Author     User      `json:"author"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Deliveries holds the status of the message for every recipient by user id while it is sent,
+	// a finished broadcast counts them and keeps only the recipients it did not reach
+	Deliveries map[int64]*Delivery `json:"deliveries,omitempty"`
+	Sent       int                 `json:"sent"`
+	Blocked    int                 `json:"blocked"`
+	Failed     int                 `json:"failed"`
+}
+
+// trimDeliveries counts the deliveries and drops the successful ones, which make up most of them
+func (b *Broadcast) trimDeliveries() {
+	for userID, delivery := range b.Deliveries {
+		switch delivery.Status {
+		case deliveryStatusSent:
+			b.Sent++
+			delete(b.Deliveries, userID)
+		case deliveryStatusBlocked:
+			b.Blocked++
+		case deliveryStatusFailed:
+			b.Failed++
+		}
+	}
+}
+
+// rateLimiter spaces out the messages of every running broadcast together
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	limiter := &rateLimiter{}
+	if rate > 0 {
+		limiter.interval = time.Second / time.Duration(rate)
+	}
+	return limiter
+}
+
+// wait blocks until the caller may send the next message
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+func (app *App) subscribe(user User, now time.Time) (bool, error) {
+	subscribed := false
+	err := app.store.update(func(data *storeData) {
+		if _, ok := data.Subscribers[user.ID]; ok {
+			return
+		}
+		data.Subscribers[user.ID] = &Subscriber{User: user, SubscribedAt: now}
+		subscribed = true
+	})
+	return subscribed, err
+}
+
+func (app *App) unsubscribe(userID int64) (bool, error) {
+	unsubscribed := false
+	err := app.store.update(func(data *storeData) {
+		_, unsubscribed = data.Subscribers[userID]
+		delete(data.Subscribers, userID)
+	})
+	return unsubscribed, err
+}
+
+func (app *App) subscriberCount() int {
+	var count int
+	app.store.view(func(data *storeData) {
+		count = len(data.Subscribers)
+	})
+	return count
+}
+
+func (app *App) handleSubscribeCommand(message *Message, args string) {
+	if message.Chat.Type != "private" {
+		params := map[string]any{"text": "Подписаться на новости можно в личных сообщениях с ботом."}
+		if link := app.deepLink(subscribeStartPayload); link != "" {
+			params["reply_markup"] = createCustomButtonsMarkup([]Button{{Text: "Подписаться", Url: link}})
+		}
+		app.reply(message, params)
+		return
+	}
+
+	subscribed, err := app.subscribe(message.From, time.Now())
+	switch {
+	case err != nil:
+		slog.Error("Error saving subscriber", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось оформить подписку, попробуйте ещё раз.")
+	case subscribed:
+		slog.Info("User subscribed", "user_id", message.From.ID)
+		app.replyText(message, "Вы подписались на новости мастерской «Мыльная Мама». Отписаться: /unsubscribe")
+	default:
+		app.replyText(message, "Вы уже подписаны. Отписаться: /unsubscribe")
+	}
+}
+
+func (app *App) handleUnsubscribeCommand(message *Message, args string) {
+	unsubscribed, err := app.unsubscribe(message.From.ID)
+	switch {
+	case err != nil:
+		slog.Error("Error removing subscriber", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось отписаться, попробуйте ещё раз.")
+	case unsubscribed:
+		slog.Info("User unsubscribed", "user_id", message.From.ID)
+		app.replyText(message, "Вы отписались от новостей. Подписаться снова: /subscribe")
+	default:
+		app.replyText(message, "Вы не подписаны на новости.")
+	}
+}
+
+func (app *App) handleBroadcastCommand(message *Message, args string) {
+	err := app.store.update(func(data *storeData) {
+		data.BroadcastDrafts[message.From.ID] = time.Now()
+		// The next post goes to the subscribers, not to the group
+		delete(data.AnnouncementDrafts, message.From.ID)
+	})
+	if err != nil {
+		slog.Error("Error saving broadcast draft", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось начать рассылку, попробуйте ещё раз.")
+		return
+	}
+	app.replyText(message, fmt.Sprintf(
+		"Рассылку получат подписчики: %d. Пришлите текст или фото с подписью. Кнопки добавляются строками в конце:\nТекст кнопки | https://example.com",
+		app.subscriberCount(),
+	))
+}
+
+func (app *App) isBroadcastMessage(message *Message) bool {
+	if message == nil || message.Chat.Type != "private" || app.config.AdminChatID == 0 || isCommand(message) {
+		return false
+	}
+	var ok bool
+	app.store.view(func(data *storeData) {
+		startedAt, found := data.BroadcastDrafts[message.From.ID]
+		ok = found && !isPostDraftExpired(startedAt, time.Now())
+	})
+	return ok
+}
+
+func (app *App) createBroadcastConfirmMarkup(broadcastID int64, recipients int) map[string]any {
+	return map[string]any{
+		"inline_keyboard": [][]map[string]string{
+			{app.callbackButton(fmt.Sprintf("Отправить (%d)", recipients), broadcastCallbackPrefix, "send", broadcastID)},
+			{app.callbackButton("Отменить", broadcastCallbackPrefix, "cancel", broadcastID)},
+		},
+	}
+}
+
+func (app *App) handleBroadcastMessage(message *Message) {
+	post, ok := app.composePost(message)
+	if !ok {
+		return
+	}
+	broadcast := Broadcast{
+		Post:      post,
+		Author:    message.From,
+		Status:    broadcastStatusDraft,
+		CreatedAt: time.Now(),
+	}
+	err := app.store.update(func(data *storeData) {
+		data.LastBroadcastID++
+		broadcast.ID = data.LastBroadcastID
+		data.Broadcasts = append(data.Broadcasts, &broadcast)
+		delete(data.BroadcastDrafts, message.From.ID)
+	})
+	if err != nil {
+		slog.Error("Error saving broadcast", "user_id", message.From.ID, "error", err)
+		app.replyText(message, "Не получилось сохранить рассылку, попробуйте ещё раз.")
+		return
+	}
+
+	recipients := app.subscriberCount()
+	app.reply(message, map[string]any{
+		"text":         fmt.Sprintf("Рассылка №%d, выше — как её увидят подписчики. Получателей: %d.", broadcast.ID, recipients),
+		"reply_markup": app.createBroadcastConfirmMarkup(broadcast.ID, recipients),
+	})
+}
+
+// changeBroadcastStatus moves a draft on to status, sending starts with a pending delivery for every subscriber
+func (app *App) changeBroadcastStatus(broadcastID int64, status string) (int, error) {
+	var recipients int
+	var changeErr error
+	err := app.store.update(func(data *storeData) {
+		index := slices.IndexFunc(data.Broadcasts, func(broadcast *Broadcast) bool { return broadcast.ID == broadcastID })
+		if index < 0 || data.Broadcasts[index].Status != broadcastStatusDraft {
+			changeErr = fmt.Errorf("broadcast %d is not a draft", broadcastID)
+			return
+		}
+		broadcast := data.Broadcasts[index]
+		broadcast.Status = status
+		if status != broadcastStatusSending {
+			return
+		}
+		broadcast.Deliveries = make(map[int64]*Delivery, len(data.Subscribers))
+		for userID := range data.Subscribers {
+			broadcast.Deliveries[userID] = &Delivery{Status: deliveryStatusPending}
+		}
+		recipients = len(broadcast.Deliveries)
+	})
+	if changeErr != nil {
+		return 0, changeErr
+	}
+	return recipients, err
+}
+
+func (app *App) handleBroadcastCallback(query *CallbackQuery, args callbackArgs) string {
+	action := args.string(0)
+	broadcastID, ok := args.int64(1)
+	if !ok || query.Message == nil || query.Message.Chat.Type != "private" || !app.isAdminUser(query.From.ID) {
+		return ""
+	}
+
+	var text string
+	switch action {
+	case "send":
+		recipients, err := app.changeBroadcastStatus(broadcastID, broadcastStatusSending)
+		if err != nil {
+			return "Рассылка уже отправлена или отменена"
+		}
+		slog.Info("Broadcast started", "id", broadcastID, "recipients", recipients, "user_id", query.From.ID)
+		text = fmt.Sprintf("Рассылка №%d отправляется подписчикам: %d. Пришлю отчёт, когда закончу.", broadcastID, recipients)
+		go app.deliverBroadcast(broadcastID)
+	case "cancel":
+		if _, err := app.changeBroadcastStatus(broadcastID, broadcastStatusCancelled); err != nil {
+			return "Рассылка уже отправлена или отменена"
+		}
+		text = fmt.Sprintf("Рассылка №%d отменена.", broadcastID)
+	default:
+		return ""
+	}
+
+	err := app.telegram.editMessageText(map[string]any{
+		"chat_id":    query.Message.Chat.ID,
+		"message_id": query.Message.MessageID,
+		"text":       text,
+	})
+	if err != nil {
+		slog.Error("Error updating broadcast message", "id", broadcastID, "error", err)
+	}
+	return ""
+}
+
+// deliverPost sends post to a subscriber, waiting out flood control a few times
+func (app *App) deliverPost(post *Post, userID int64) Delivery {
+	for attempt := 1; ; attempt++ {
+		err := app.sendPost(post, userID, 0)
+		if err == nil {
+			return Delivery{Status: deliveryStatusSent}
+		}
+		var apiErr *ApiError
+		if errors.As(err, &apiErr) {
+			// The user blocked the bot or deleted their account
+			if apiErr.Code == 403 {
+				return Delivery{Status: deliveryStatusBlocked, Error: apiErr.Description}
+			}
+			if apiErr.Code == 429 && apiErr.RetryAfter > 0 && attempt < maxDeliveryAttempts {
+				time.Sleep(time.Duration(apiErr.RetryAfter) * time.Second)
+				continue
+			}
+		}
+		return Delivery{Status: deliveryStatusFailed, Error: err.Error()}
+	}
+}
+
+func (app *App) findBroadcast(broadcastID int64) (Broadcast, []int64, bool) {
+	var broadcast Broadcast
+	var pending []int64
+	var ok bool
+	app.store.view(func(data *storeData) {
+		index := slices.IndexFunc(data.Broadcasts, func(broadcast *Broadcast) bool { return broadcast.ID == broadcastID })
+		if index < 0 {
+			return
+		}
+		broadcast, ok = *data.Broadcasts[index], true
+		// The deliveries keep changing while the broadcast is sent
+		broadcast.Deliveries = make(map[int64]*Delivery, len(data.Broadcasts[index].Deliveries))
+		for userID, delivery := range data.Broadcasts[index].Deliveries {
+			copied := *delivery
+			broadcast.Deliveries[userID] = &copied
+			if delivery.Status == deliveryStatusPending {
+				pending = append(pending, userID)
+			}
+		}
+	})
+	slices.Sort(pending)
+	return broadcast, pending, ok
+}
+
+func createBroadcastReport(broadcast *Broadcast) string {
+	return fmt.Sprintf(
+		"Рассылка №%d завершена.\nДоставлено: %d\nЗаблокировали бота и отписаны: %d\nОшибки: %d",
+		broadcast.ID, broadcast.Sent, broadcast.Blocked, broadcast.Failed,
+	)
+}
+
+// saveDeliveries stores a batch of deliveries and unsubscribes the users who blocked the bot
+func (app *App) saveDeliveries(broadcastID int64, deliveries map[int64]*Delivery) {
+	if len(deliveries) == 0 {
+		return
+	}
+	err := app.store.update(func(data *storeData) {
+		index := slices.IndexFunc(data.Broadcasts, func(broadcast *Broadcast) bool { return broadcast.ID == broadcastID })
+		for userID, delivery := range deliveries {
+			data.Broadcasts[index].Deliveries[userID] = delivery
+			if delivery.Status == deliveryStatusBlocked {
+				delete(data.Subscribers, userID)
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error saving broadcast deliveries", "id", broadcastID, "deliveries", len(deliveries), "error", err)
+	}
+}
+
+// deliverBroadcast sends a broadcast to its pending recipients, all broadcasts together send
+// subscriptions.rate messages per second. Deliveries are saved every second, so a broadcast
+// interrupted by a restart continues where it stopped, repeating at most the last second.
+func (app *App) deliverBroadcast(broadcastID int64) {
+	broadcast, pending, ok := app.findBroadcast(broadcastID)
+	if !ok {
+		return
+	}
+
+	batch := make(map[int64]*Delivery)
+	savedAt := time.Now()
+	for _, userID := range pending {
+		app.broadcastLimiter.wait()
+		delivery := app.deliverPost(&broadcast.Post, userID)
+		batch[userID] = &delivery
+		if delivery.Status == deliveryStatusBlocked {
+			slog.Info("Unsubscribing user who blocked the bot", "user_id", userID)
+		}
+		if time.Since(savedAt) >= deliverySavePeriod {
+			app.saveDeliveries(broadcastID, batch)
+			batch = make(map[int64]*Delivery)
+			savedAt = time.Now()
+		}
+	}
+	app.saveDeliveries(broadcastID, batch)
+
+	err := app.store.update(func(data *storeData) {
+		index := slices.IndexFunc(data.Broadcasts, func(broadcast *Broadcast) bool { return broadcast.ID == broadcastID })
+		data.Broadcasts[index].Status = broadcastStatusDone
+		data.Broadcasts[index].FinishedAt = time.Now()
+		data.Broadcasts[index].trimDeliveries()
+	})
+	if err != nil {
+		slog.Error("Error saving broadcast status", "id", broadcastID, "error", err)
+	}
+
+	broadcast, _, _ = app.findBroadcast(broadcastID)
+	slog.Info("Broadcast finished", "id", broadcastID, "sent", broadcast.Sent)
+	_, err = app.telegram.sendMessage(map[string]any{
+		"chat_id": broadcast.Author.ID,
+		"text":    createBroadcastReport(&broadcast),
+	})
+	if err != nil {
+		slog.Error("Error sending broadcast report", "id", broadcastID, "error", err)
+	}
+}
+
+// resumeBroadcasts continues the broadcasts that were being sent when the bot stopped
+func (app *App) resumeBroadcasts() {
+	var sending []int64
+	app.store.view(func(data *storeData) {
+		for _, broadcast := range data.Broadcasts {
+			if broadcast.Status == broadcastStatusSending {
+				sending = append(sending, broadcast.ID)
+			}
+		}
+	})
+	for _, broadcastID := range sending {
+		slog.Info("Resuming broadcast", "id", broadcastID)
+		go app.deliverBroadcast(broadcastID)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func subscriptionsTestConfig(config *Config) {
+	config.Subscriptions = Subscriptions{Rate: maxBroadcastRate}
+}
+
+func createTestBroadcastCallback(app *App, payload string) *Update {
+	return &Update{CallbackQuery: &CallbackQuery{
+		ID:      "query",
+		From:    User{ID: testCustomerID, FirstName: "Jane"},
+		Data:    app.callbacks.sign(payload),
+		Message: &Message{MessageID: 42, Chat: Chat{ID: testCustomerID, Type: "private"}},
+	}}
+}
+
+// waitForBroadcast waits for the report that ends a broadcast
+func waitForBroadcast(t *testing.T, app *App, api *fakeTelegramApi, broadcastID int64) Broadcast {
+	t.Helper()
+	report := fmt.Sprintf("Рассылка №%d завершена", broadcastID)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, call := range api.callsTo("sendMessage") {
+			if strings.HasPrefix(call.Params["text"].(string), report) {
+				broadcast, _, _ := app.findBroadcast(broadcastID)
+				return broadcast
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected broadcast %d to finish", broadcastID)
+	return Broadcast{}
+}
+
+func TestSubscribeAndUnsubscribe(t *testing.T) {
+	app, api := newTestApp(t, subscriptionsTestConfig)
+	setChatMemberStatus(api, "member")
+
+	tests := []struct {
+		text     string
+		expected string
+		count    int
+	}{
+		{text: "/start subscribe", expected: "Вы подписались", count: 1},
+		{text: "/subscribe", expected: "Вы уже подписаны", count: 1},
+		{text: "/unsubscribe", expected: "Вы отписались", count: 0},
+		{text: "/unsubscribe", expected: "Вы не подписаны", count: 0},
+	}
+
+	for _, tt := range tests {
+		app.handleTelegramUpdate(createTestPrivateMessage(tt.text))
+		if text := lastSentText(t, api); !strings.Contains(text, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.text, tt.expected, text)
+		}
+		if count := app.subscriberCount(); count != tt.count {
+			t.Errorf("%s: expected %d subscribers, got %d", tt.text, tt.count, count)
+		}
+	}
+}
+
+func TestSubscribeInGroup(t *testing.T) {
+	app, api := newTestApp(t, subscriptionsTestConfig)
+	setChatMemberStatus(api, "member")
+
+	app.handleTelegramUpdate(createTestCommand("/subscribe"))
+
+	if app.subscriberCount() != 0 {
+		t.Error("Expected no subscription from the group")
+	}
+	params := api.callsTo("sendMessage")[0].Params
+	button := params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)[0].([]any)[0].(map[string]any)
+	if button["url"] != "https://t.me/soapmama_bot?start=subscribe" {
+		t.Errorf("Expected deep link to subscribe, got %v", button["url"])
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	app, api := newTestApp(t, subscriptionsTestConfig)
+	setChatMemberStatus(api, "member")
+	for _, userID := range []int64{1001, 1002, 1003} {
+		app.subscribe(User{ID: userID}, time.Now())
+	}
+	api.setChatResponse("sendMessage", 1002, `{"ok": false, "error_code": 403, "description": "Forbidden: bot was blocked by the user"}`)
+	api.setChatResponse("sendMessage", 1003, `{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`)
+
+	app.handleTelegramUpdate(createTestPrivateMessage("/broadcast"))
+	app.handleTelegramUpdate(createTestPrivateMessage("Новинки недели\nКаталог | https://example.com/catalog"))
+
+	calls := api.callsTo("sendMessage")
+	preview := calls[len(calls)-2].Params
+	if preview["chat_id"] != float64(testCustomerID) || preview["text"] != "Новинки недели" {
+		t.Errorf("Expected a preview to the admin, got %v", preview)
+	}
+	if !strings.Contains(calls[len(calls)-1].Params["text"].(string), "Получателей: 3") {
+		t.Errorf("Expected the confirmation with recipients, got %v", calls[len(calls)-1].Params)
+	}
+	data := keyboardData(app, app.createBroadcastConfirmMarkup(1, 3)["inline_keyboard"].([][]map[string]string))
+	if strings.Join(data, ",") != "broadcast:send:1,broadcast:cancel:1" {
+		t.Errorf("Expected send and cancel buttons, got %v", data)
+	}
+
+	app.handleTelegramUpdate(createTestBroadcastCallback(app, "broadcast:send:1"))
+	broadcast := waitForBroadcast(t, app, api, 1)
+
+	if broadcast.Sent != 1 || broadcast.Blocked != 1 || broadcast.Failed != 1 {
+		t.Errorf("Expected the deliveries to be counted, got %+v", broadcast)
+	}
+	if len(broadcast.Deliveries) != 2 || broadcast.Deliveries[1002].Status != deliveryStatusBlocked ||
+		broadcast.Deliveries[1003].Status != deliveryStatusFailed || broadcast.Deliveries[1003].Error == "" {
+		t.Errorf("Expected the recipients that were not reached to be kept, got %v", broadcast.Deliveries)
+	}
+	app.store.view(func(data *storeData) {
+		if _, ok := data.Subscribers[1002]; ok {
+			t.Error("Expected the user who blocked the bot to be unsubscribed")
+		}
+		if _, ok := data.Subscribers[1003]; !ok {
+			t.Error("Expected other failures to keep the subscription")
+		}
+	})
+	if text := lastSentText(t, api); !strings.Contains(text, "Доставлено: 1\nЗаблокировали бота и отписаны: 1\nОшибки: 1") {
+		t.Errorf("Expected a report, got %q", text)
+	}
+
+	app.handleTelegramUpdate(createTestBroadcastCallback(app, "broadcast:send:1"))
+	answers := api.callsTo("answerCallbackQuery")
+	if answers[len(answers)-1].Params["text"] != "Рассылка уже отправлена или отменена" {
+		t.Errorf("Expected a second send to be rejected, got %v", answers[len(answers)-1].Params)
+	}
+}
+
+func TestBroadcastCancel(t *testing.T) {
+	app, api := newTestApp(t, subscriptionsTestConfig)
+	setChatMemberStatus(api, "member")
+	app.subscribe(User{ID: 1001}, time.Now())
+	app.handleTelegramUpdate(createTestPrivateMessage("/broadcast"))
+	app.handleTelegramUpdate(createTestPrivateMessage("Новинки недели"))
+	sent := len(api.callsTo("sendMessage"))
+
+	app.handleTelegramUpdate(createTestBroadcastCallback(app, "broadcast:cancel:1"))
+	app.handleTelegramUpdate(createTestBroadcastCallback(app, "broadcast:send:1"))
+
+	if calls := api.callsTo("sendMessage"); len(calls) != sent {
+		t.Errorf("Expected nothing to be sent after cancelling, got %v", calls[sent:])
+	}
+	if broadcast, _, _ := app.findBroadcast(1); broadcast.Status != broadcastStatusCancelled {
+		t.Errorf("Expected cancelled broadcast, got %s", broadcast.Status)
+	}
+}
+
+func TestResumeBroadcast(t *testing.T) {
+	app, api := newTestApp(t, subscriptionsTestConfig)
+	setChatMemberStatus(api, "member")
+	app.store.update(func(data *storeData) {
+		data.Broadcasts = []*Broadcast{{
+			ID:     1,
+			Post:   Post{Text: "Новинки недели"},
+			Author: User{ID: testCustomerID},
+			Status: broadcastStatusSending,
+			Deliveries: map[int64]*Delivery{
+				1001: {Status: deliveryStatusSent},
+				1002: {Status: deliveryStatusPending},
+			},
+		}}
+	})
+
+	app.resumeBroadcasts()
+	waitForBroadcast(t, app, api, 1)
+
+	var recipients []any
+	for _, call := range api.callsTo("sendMessage") {
+		if call.Params["text"] == "Новинки недели" {
+			recipients = append(recipients, call.Params["chat_id"])
+		}
+	}
+	if len(recipients) != 1 || recipients[0] != float64(1002) {
+		t.Errorf("Expected only the pending recipient, got %v", recipients)
+	}
+	if broadcast, _, _ := app.findBroadcast(1); broadcast.Sent != 2 {
+		t.Errorf("Expected the deliveries before the restart to be counted, got %+v", broadcast)
+	}
+}
+
+func TestRateLimiterIsShared(t *testing.T) {
+	limiter := newRateLimiter(20)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.wait()
+		}()
+	}
+	wg.Wait()
+
+	// Five messages at 20 per second take at least four intervals of 50ms whoever sends them
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected the messages to be spaced out, took %v", elapsed)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+const defaultTelegramApiUrl = "https://api.telegram.org"
+
+type TelegramClient struct {
+	apiUrl     string
+	token      string
+	httpClient *http.Client
+}
+
+type responseParameters struct {
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+type apiResponse struct {
+	OK          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result"`
+	ErrorCode   int                 `json:"error_code"`
+	Description string              `json:"description"`
+	Parameters  *responseParameters `json:"parameters,omitempty"`
+}
+
+type ApiError struct {
+	Method      string
+	Code        int
+	Description string
+	// RetryAfter is how many seconds to wait after hitting flood control
+	RetryAfter int
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("telegram %s: %d %s", e.Method, e.Code, e.Description)
+}
+
+func newTelegramClient(apiUrl, token string) *TelegramClient {
+	if apiUrl == "" {
+		apiUrl = defaultTelegramApiUrl
+	}
+	return &TelegramClient{
+		apiUrl:     apiUrl,
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *TelegramClient) methodUrl(method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", c.apiUrl, c.token, method)
+}
+
+func (c *TelegramClient) call(method string, params any, result any) error {
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.callRaw(method, "application/json", bytes.NewReader(jsonData), result)
+}
+
+func (c *TelegramClient) callRaw(method string, contentType string, body io.Reader, result any) error {
+	resp, err := c.httpClient.Post(c.methodUrl(method), contentType, body)
+	if err != nil {
+		// The URL contains the token, so only the method name is reported
+		return fmt.Errorf("telegram %s: request failed", method)
+	}
+	defer resp.Body.Close()
+
+	var response apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("telegram %s: %s: %w", method, resp.Status, err)
+	}
+	if !response.OK {
+		apiErr := &ApiError{Method: method, Code: response.ErrorCode, Description: response.Description}
+		if response.Parameters != nil {
+			apiErr.RetryAfter = response.Parameters.RetryAfter
+		}
+		return apiErr
+	}
+	slog.Info("Called telegram method", "method", method, "status", resp.Status)
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+type InputFile struct {
+	Name   string
+	Reader io.Reader
+}
+
+func multipartValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		jsonData, err := json.Marshal(v)
+		return string(jsonData), err
+	}
+}
+
+// callMultipart uploads files as form fields named after the map keys,
+// other parameters are sent as form values with nested objects JSON-encoded.
+func (c *TelegramClient) callMultipart(method string, params map[string]any, files map[string]InputFile, result any) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for key, value := range params {
+		formValue, err := multipartValue(value)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteField(key, formValue); err != nil {
+			return err
+		}
+	}
+	for field, file := range files {
+		part, err := writer.CreateFormFile(field, file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, file.Reader); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return c.callRaw(method, writer.FormDataContentType(), &body, result)
+}
+
+func (c *TelegramClient) sendMessage(params map[string]any) (*Message, error) {
+	var message Message
+	if err := c.call("sendMessage", params, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (c *TelegramClient) editMessageText(params map[string]any) error {
+	return c.call("editMessageText", params, nil)
+}
+
+func (c *TelegramClient) answerCallbackQuery(callbackQueryID string, text string) error {
+	params := map[string]any{
+		"callback_query_id": callbackQueryID,
+	}
+	if text != "" {
+		params["text"] = text
+	}
+	return c.call("answerCallbackQuery", params, nil)
+}
+
+func (c *TelegramClient) answerInlineQuery(params map[string]any) error {
+	return c.call("answerInlineQuery", params, nil)
+}
+
+func (c *TelegramClient) approveChatJoinRequest(chatID int64, userID int64) error {
+	return c.call("approveChatJoinRequest", map[string]any{
+		"chat_id": chatID,
+		"user_id": userID,
+	}, nil)
+}
+
+func (c *TelegramClient) declineChatJoinRequest(chatID int64, userID int64) error {
+	return c.call("declineChatJoinRequest", map[string]any{
+		"chat_id": chatID,
+		"user_id": userID,
+	}, nil)
+}
+
+func (c *TelegramClient) getChatMember(chatID int64, userID int64) (*ChatMember, error) {
+	var member ChatMember
+	err := c.call("getChatMember", map[string]any{
+		"chat_id": chatID,
+		"user_id": userID,
+	}, &member)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (c *TelegramClient) createChatInviteLink(params map[string]any) (*ChatInviteLink, error) {
+	var link ChatInviteLink
+	if err := c.call("createChatInviteLink", params, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (c *TelegramClient) revokeChatInviteLink(chatID int64, inviteLink string) error {
+	return c.call("revokeChatInviteLink", map[string]any{
+		"chat_id":     chatID,
+		"invite_link": inviteLink,
+	}, nil)
+}
+
+func (c *TelegramClient) banChatMember(chatID int64, userID int64) error {
+	return c.call("banChatMember", map[string]any{
+		"chat_id": chatID,
+		"user_id": userID,
+	}, nil)
+}
+
+func (c *TelegramClient) unbanChatMember(chatID int64, userID int64) error {
+	return c.call("unbanChatMember", map[string]any{
+		"chat_id":        chatID,
+		"user_id":        userID,
+		"only_if_banned": true,
+	}, nil)
+}
+
+type WebhookInfo struct {
+	Url                  string   `json:"url"`
+	PendingUpdateCount   int      `json:"pending_update_count"`
+	LastErrorDate        int64    `json:"last_error_date,omitempty"`
+	LastErrorMessage     string   `json:"last_error_message,omitempty"`
+	MaxConnections       int      `json:"max_connections,omitempty"`
+	AllowedUpdates       []string `json:"allowed_updates,omitempty"`
+	HasCustomCertificate bool     `json:"has_custom_certificate"`
+}
+
+func (c *TelegramClient) setWebhook(url string, allowedUpdates []string, dropPendingUpdates bool, secretToken string) error {
+	return c.call("setWebhook", map[string]any{
+		"url":                  url,
+		"allowed_updates":      allowedUpdates,
+		"drop_pending_updates": dropPendingUpdates,
+		"secret_token":         secretToken,
+	}, nil)
+}
+
+func (c *TelegramClient) deleteWebhook(dropPendingUpdates bool) error {
+	return c.call("deleteWebhook", map[string]any{
+		"drop_pending_updates": dropPendingUpdates,
+	}, nil)
+}
+
+func (c *TelegramClient) getWebhookInfo() (*WebhookInfo, error) {
+	var info WebhookInfo
+	if err := c.call("getWebhookInfo", map[string]any{}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordedCall struct {
+	Method string
+	Params map[string]any
+}
+
+// fakeTelegramApi records every Bot API call and answers with "ok": true
+// unless a response for the method, or for the method in a chat, is set in responses.
+// Responses queued for a method answer its next calls first.
+type fakeTelegramApi struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	calls     []recordedCall
+	responses map[string]string
+	queued    map[string][]string
+}
+
+func newFakeTelegramApi(t *testing.T) *fakeTelegramApi {
+	api := &fakeTelegramApi{responses: make(map[string]string), queued: make(map[string][]string)}
+	api.server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+// newTestApp builds an app on the fake Bot API with the settings most tests share,
+// configure adjusts them for a test
+func newTestApp(t *testing.T, configure func(config *Config)) (*App, *fakeTelegramApi) {
+	api := newFakeTelegramApi(t)
+	config := &Config{
+		ApiUrl:      api.server.URL,
+		Token:       "test_token",
+		BotUsername: "soapmama_bot",
+		ChatID:      123456789,
+		AdminChatID: 555,
+		Links: Links{
+			Distillate: "https://example.com/distillate",
+			Prices:     "https://example.com/prices",
+			Soap:       "https://example.com/soap",
+			Ubtan:      "https://example.com/ubtan",
+		},
+	}
+	if configure != nil {
+		configure(config)
+	}
+	return newApp(config, newMemoryStore()), api
+}
+
+// setChatMemberStatus answers getChatMember with status for every user
+func setChatMemberStatus(api *fakeTelegramApi, status string) {
+	api.setResponse("getChatMember", `{"ok": true, "result": {"status": "`+status+`", "user": {"id": 111222333}}}`)
+}
+
+func (api *fakeTelegramApi) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	params := make(map[string]any)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		// Uploaded files are recorded as "file:<name>" under their field name
+		r.ParseMultipartForm(10 << 20)
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values[0]
+		}
+		for key, files := range r.MultipartForm.File {
+			params[key] = "file:" + files[0].Filename
+		}
+	} else {
+		json.NewDecoder(r.Body).Decode(&params)
+	}
+
+	api.mu.Lock()
+	api.calls = append(api.calls, recordedCall{Method: method, Params: params})
+	response, ok := api.responses[method]
+	if chatID, isNumber := params["chat_id"].(float64); isNumber {
+		if chatResponse, found := api.responses[fmt.Sprintf("%s:%d", method, int64(chatID))]; found {
+			response, ok = chatResponse, true
+		}
+	}
+	if queued := api.queued[method]; len(queued) > 0 {
+		response, ok = queued[0], true
+		api.queued[method] = queued[1:]
+	}
+	api.mu.Unlock()
+
+	if !ok {
+		response = `{"ok": true, "result": {}}`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(response))
+}
+
+func (api *fakeTelegramApi) client() *TelegramClient {
+	return newTelegramClient(api.server.URL, "test_token")
+}
+
+func (api *fakeTelegramApi) setResponse(method string, response string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.responses[method] = response
+}
+
+func (api *fakeTelegramApi) queueResponse(method string, response string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.queued[method] = append(api.queued[method], response)
+}
+
+func (api *fakeTelegramApi) setChatResponse(method string, chatID int64, response string) {
+	api.setResponse(fmt.Sprintf("%s:%d", method, chatID), response)
+}
+
+func (api *fakeTelegramApi) recordedCalls() []recordedCall {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]recordedCall(nil), api.calls...)
+}
+
+func (api *fakeTelegramApi) callsTo(method string) []recordedCall {
+	var calls []recordedCall
+	for _, call := range api.recordedCalls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func TestTelegramClientMethodUrl(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiUrl   string
+		token    string
+		expected string
+	}{
+		{
+			name:     "valid token",
+			token:    "123456789:ABCdefGHIjklMNOpqrsTUVwxyz",
+			expected: "https://api.telegram.org/bot123456789:ABCdefGHIjklMNOpqrsTUVwxyz/sendMessage",
+		},
+		{
+			name:     "empty token",
+			token:    "",
+			expected: "https://api.telegram.org/bot/sendMessage",
+		},
+		{
+			name:     "custom api url",
+			apiUrl:   "http://localhost:8081",
+			token:    "test_token",
+			expected: "http://localhost:8081/bottest_token/sendMessage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTelegramClient(tt.apiUrl, tt.token)
+			result := client.methodUrl("sendMessage")
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTelegramClientSendMessage(t *testing.T) {
+	api := newFakeTelegramApi(t)
+	api.setResponse("sendMessage", `{"ok": true, "result": {"message_id": 42, "chat": {"id": 123456789}}}`)
+
+	message, err := api.client().sendMessage(map[string]any{
+		"chat_id": 123456789,
+		"text":    "Привет",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.MessageID != 42 {
+		t.Errorf("Expected message_id 42, got %d", message.MessageID)
+	}
+
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 sendMessage call, got %d", len(calls))
+	}
+	if calls[0].Params["text"] != "Привет" {
+		t.Errorf("Expected text 'Привет', got %v", calls[0].Params["text"])
+	}
+}
+
+func TestTelegramClientApiError(t *testing.T) {
+	api := newFakeTelegramApi(t)
+	api.setResponse("approveChatJoinRequest", `{"ok": false, "error_code": 400, "description": "Bad Request: HIDE_REQUESTER_MISSING"}`)
+
+	err := api.client().approveChatJoinRequest(123456789, 111222333)
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected ApiError, got %v", err)
+	}
+	if apiErr.Code != 400 {
+		t.Errorf("Expected error code 400, got %d", apiErr.Code)
+	}
+	if strings.Contains(err.Error(), "test_token") {
+		t.Error("Expected error to not contain the token")
+	}
+}
+
+func TestTelegramClientRetryAfter(t *testing.T) {
+	api := newFakeTelegramApi(t)
+	api.setResponse("sendMessage", `{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 5", "parameters": {"retry_after": 5}}`)
+
+	_, err := api.client().sendMessage(map[string]any{"chat_id": 123456789, "text": "Привет"})
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 5 {
+		t.Errorf("Expected retry after 5 seconds, got %v", err)
+	}
+}
+
+func TestTelegramClientCallMultipart(t *testing.T) {
+	api := newFakeTelegramApi(t)
+
+	err := api.client().callMultipart("sendPhoto", map[string]any{
+		"chat_id":      int64(123456789),
+		"caption":      "Привет",
+		"reply_markup": map[string]any{"inline_keyboard": [][]map[string]string{}},
+	}, map[string]InputFile{
+		"photo": {Name: "soap.jpg", Reader: strings.NewReader("jpeg")},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("sendPhoto")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 sendPhoto call, got %d", len(calls))
+	}
+	expected := map[string]any{
+		"chat_id":      "123456789",
+		"caption":      "Привет",
+		"reply_markup": `{"inline_keyboard":[]}`,
+		"photo":        "file:soap.jpg",
+	}
+	for key, value := range expected {
+		if calls[0].Params[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, calls[0].Params[key])
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultTelegraphApiUrl = "https://api.telegra.ph"
+
+type TelegraphClient struct {
+	apiUrl      string
+	accessToken string
+	httpClient  *http.Client
+}
+
+type TelegraphPage struct {
+	Path  string `json:"path"`
+	Url   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type telegraphResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+func newTelegraphClient(apiUrl string, accessToken string) *TelegraphClient {
+	if apiUrl == "" {
+		apiUrl = defaultTelegraphApiUrl
+	}
+	return &TelegraphClient{
+		apiUrl:      strings.TrimSuffix(apiUrl, "/"),
+		accessToken: accessToken,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *TelegraphClient) call(method string, params url.Values, result any) error {
+	params.Set("access_token", c.accessToken)
+	resp, err := c.httpClient.PostForm(c.apiUrl+"/"+method, params)
+	if err != nil {
+		// The request may contain the access token, so only the method name is reported
+		return fmt.Errorf("telegraph %s: request failed", method)
+	}
+	defer resp.Body.Close()
+
+	var response telegraphResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("telegraph %s: %s: %w", method, resp.Status, err)
+	}
+	if !response.OK {
+		return fmt.Errorf("telegraph %s: %s", method, response.Error)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func pageParams(title string, authorName string, content []any) (url.Values, error) {
+	contentJson, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("title", title)
+	params.Set("content", string(contentJson))
+	if authorName != "" {
+		params.Set("author_name", authorName)
+	}
+	return params, nil
+}
+
+func (c *TelegraphClient) createPage(title string, authorName string, content []any) (*TelegraphPage, error) {
+	params, err := pageParams(title, authorName, content)
+	if err != nil {
+		return nil, err
+	}
+	var page TelegraphPage
+	if err := c.call("createPage", params, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (c *TelegraphClient) editPage(path string, title string, authorName string, content []any) (*TelegraphPage, error) {
+	params, err := pageParams(title, authorName, content)
+	if err != nil {
+		return nil, err
+	}
+	var page TelegraphPage
+	if err := c.call("editPage/"+url.PathEscape(path), params, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// PublishedPage is a content/*.md file published to Telegraph, keyed by the file name without extension
+type PublishedPage struct {
+	Path        string    `json:"path"`
+	Url         string    `json:"url"`
+	ContentHash string    `json:"content_hash"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type publishResult struct {
+	Name    string
+	Url     string
+	Changed bool
+	Err     error
+}
+
+func contentHash(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+func (app *App) publishContentFile(client *TelegraphClient, path string, now time.Time) publishResult {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	result := publishResult{Name: name}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	title, content := markdownToTelegraph(string(source))
+	if title == "" {
+		result.Err = errors.New("no \"# \" title")
+		return result
+	}
+
+	var published PublishedPage
+	var exists bool
+	app.store.view(func(data *storeData) {
+		published, exists = data.TelegraphPages[name]
+	})
+	hash := contentHash(source)
+	if exists && published.ContentHash == hash {
+		result.Url = published.Url
+		return result
+	}
+
+	var page *TelegraphPage
+	authorName := app.config.Telegraph.AuthorName
+	if exists {
+		page, err = client.editPage(published.Path, title, authorName, content)
+	} else {
+		page, err = client.createPage(title, authorName, content)
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	err = app.store.update(func(data *storeData) {
+		data.TelegraphPages[name] = PublishedPage{Path: page.Path, Url: page.Url, ContentHash: hash, PublishedAt: now}
+	})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Url = page.Url
+	result.Changed = true
+	return result
+}
+
+// publishContent creates or edits a Telegraph page for every Markdown file in the content directory.
+// Pages whose file didn't change since the last publish are skipped.
+func (app *App) publishContent(now time.Time) ([]publishResult, error) {
+	settings := &app.config.Telegraph
+	paths, err := filepath.Glob(filepath.Join(settings.ContentDir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	client := newTelegraphClient(settings.ApiUrl, settings.AccessToken)
+	var results []publishResult
+	for _, path := range paths {
+		result := app.publishContentFile(client, path, now)
+		if result.Err != nil {
+			slog.Error("Error publishing content", "file", path, "error", result.Err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// links returns the configured links with the ones published from content/*.md replaced by their pages
+func (app *App) links() Links {
+	links := app.config.Links
+	app.store.view(func(data *storeData) {
+		overrides := map[string]*string{
+			"distillate": &links.Distillate,
+			"prices":     &links.Prices,
+			"soap":       &links.Soap,
+			"ubtan":      &links.Ubtan,
+		}
+		for name, link := range overrides {
+			if page, ok := data.TelegraphPages[name]; ok && page.Url != "" {
+				*link = page.Url
+			}
+		}
+	})
+	return links
+}
+
+// renderLinks replaces {distillate}, {prices}, {soap} and {ubtan} in text with the current links
+func renderLinks(text string, links *Links) string {
+	return strings.NewReplacer(
+		"{distillate}", links.Distillate,
+		"{prices}", links.Prices,
+		"{soap}", links.Soap,
+		"{ubtan}", links.Ubtan,
+	).Replace(text)
+}
+
+func createPublishReport(results []publishResult) string {
+	if len(results) == 0 {
+		return "В папке с контентом нет файлов .md."
+	}
+	var lines []string
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			lines = append(lines, fmt.Sprintf("%s: ошибка: %s", result.Name, result.Err))
+		case result.Changed:
+			lines = append(lines, fmt.Sprintf("%s: опубликовано %s", result.Name, result.Url))
+		default:
+			lines = append(lines, fmt.Sprintf("%s: без изменений %s", result.Name, result.Url))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (app *App) handlePublishCommand(message *Message, args string) {
+	if app.config.Telegraph.AccessToken == "" {
+		app.replyText(message, "TELEGRAPH_TOKEN не задан.")
+		return
+	}
+	results, err := app.publishContent(time.Now())
+	if err != nil {
+		app.replyText(message, "Не удалось прочитать папку с контентом: "+err.Error())
+		return
+	}
+	app.replyText(message, createPublishReport(results))
+}
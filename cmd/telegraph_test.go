@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTelegraphApi is a local stand-in for api.telegra.ph that keeps pages in memory
+type fakeTelegraphApi struct {
+	mu      sync.Mutex
+	server  *httptest.Server
+	calls   []string
+	content map[string]string
+}
+
+func newFakeTelegraphApi(t *testing.T) *fakeTelegraphApi {
+	api := &fakeTelegraphApi{content: make(map[string]string)}
+	api.server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+func (api *fakeTelegraphApi) handle(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	w.Header().Set("Content-Type", "application/json")
+	if r.Form.Get("access_token") != "test_telegraph_token" {
+		w.Write([]byte(`{"ok": false, "error": "ACCESS_TOKEN_INVALID"}`))
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.calls = append(api.calls, r.URL.Path)
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/editPage/")
+	if !ok {
+		path = fmt.Sprintf("Page-%d", len(api.content)+1)
+	}
+	api.content[path] = r.Form.Get("content")
+	json.NewEncoder(w).Encode(map[string]any{
+		"ok": true,
+		"result": map[string]string{
+			"path":  path,
+			"url":   "https://telegra.ph/" + path,
+			"title": r.Form.Get("title"),
+		},
+	})
+}
+
+func (api *fakeTelegraphApi) recordedCalls() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]string(nil), api.calls...)
+}
+
+func newTelegraphTestApp(t *testing.T) (*App, *fakeTelegramApi, *fakeTelegraphApi) {
+	telegraph := newFakeTelegraphApi(t)
+	app, api := newTestApp(t, func(config *Config) {
+		config.Telegraph = Telegraph{
+			ApiUrl:      telegraph.server.URL,
+			AccessToken: "test_telegraph_token",
+			ContentDir:  t.TempDir(),
+		}
+	})
+	return app, api, telegraph
+}
+
+func writeTestContent(t *testing.T, app *App, name string, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(app.config.Telegraph.ContentDir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTelegraphClientError(t *testing.T) {
+	telegraph := newFakeTelegraphApi(t)
+	client := newTelegraphClient(telegraph.server.URL, "wrong_token")
+
+	_, err := client.createPage("Title", "", []any{"text"})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if !strings.Contains(err.Error(), "ACCESS_TOKEN_INVALID") {
+		t.Errorf("Expected the Telegraph error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "wrong_token") {
+		t.Errorf("Expected the error not to contain the token, got %v", err)
+	}
+}
+
+func TestPublishContent(t *testing.T) {
+	app, _, telegraph := newTelegraphTestApp(t)
+	writeTestContent(t, app, "ubtan.md", "# Что такое убтан\n\nСмесь трав.")
+	writeTestContent(t, app, "broken.md", "Нет заголовка")
+
+	results, err := app.publishContent(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Name != "broken" || results[0].Err == nil {
+		t.Errorf("Expected broken.md to fail, got %+v", results[0])
+	}
+	if results[1].Name != "ubtan" || !results[1].Changed || results[1].Url != "https://telegra.ph/Page-1" {
+		t.Errorf("Expected ubtan.md to be created, got %+v", results[1])
+	}
+
+	// Unchanged files are skipped
+	results, _ = app.publishContent(time.Now())
+	if results[1].Changed {
+		t.Error("Expected unchanged ubtan.md to be skipped")
+	}
+
+	writeTestContent(t, app, "ubtan.md", "# Что такое убтан\n\nСмесь трав и муки.")
+	results, _ = app.publishContent(time.Now())
+	if !results[1].Changed || results[1].Url != "https://telegra.ph/Page-1" {
+		t.Errorf("Expected the existing page to be edited, got %+v", results[1])
+	}
+
+	expectedCalls := []string{"/createPage", "/editPage/Page-1"}
+	if calls := telegraph.recordedCalls(); strings.Join(calls, ",") != strings.Join(expectedCalls, ",") {
+		t.Errorf("Expected calls %v, got %v", expectedCalls, calls)
+	}
+	if content := telegraph.content["Page-1"]; !strings.Contains(content, "Смесь трав и муки.") {
+		t.Errorf("Expected edited content, got %s", content)
+	}
+}
+
+func TestLinksUsePublishedPages(t *testing.T) {
+	app, _, _ := newTelegraphTestApp(t)
+	writeTestContent(t, app, "ubtan.md", "# Что такое убтан\n\nСмесь трав.")
+	writeTestContent(t, app, "delivery.md", "# Доставка\n\nПочтой.")
+
+	if _, err := app.publishContent(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	links := app.links()
+	if links.Ubtan == "https://example.com/ubtan" || !strings.HasPrefix(links.Ubtan, "https://telegra.ph/") {
+		t.Errorf("Expected ubtan link to point at the published page, got %s", links.Ubtan)
+	}
+	if links.Prices != "https://example.com/prices" {
+		t.Errorf("Expected prices link to stay configured, got %s", links.Prices)
+	}
+	if app.config.Links.Ubtan != "https://example.com/ubtan" {
+		t.Error("Expected the config to stay unchanged")
+	}
+}
+
+func TestPublishCommandOnlyInAdminChat(t *testing.T) {
+	app, api, _ := newTelegraphTestApp(t)
+	writeTestContent(t, app, "soap.md", "# Что такое крафтовое мыло")
+
+	app.handleTelegramUpdate(createTestCommand("/publish"))
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("Expected /publish to be ignored outside the admin chat")
+	}
+
+	app.handleTelegramUpdate(&Update{Message: &Message{Text: "/publish", Chat: Chat{ID: 555}}})
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(calls))
+	}
+	if text := calls[0].Params["text"].(string); !strings.Contains(text, "soap: опубликовано https://telegra.ph/Page-1") {
+		t.Errorf("Expected published page in report, got %s", text)
+	}
+}
+
+func TestPublishCommandWithoutToken(t *testing.T) {
+	app, api, telegraph := newTelegraphTestApp(t)
+	app.config.Telegraph.AccessToken = ""
+	writeTestContent(t, app, "soap.md", "# Что такое крафтовое мыло")
+
+	app.handleTelegramUpdate(&Update{Message: &Message{Text: "/publish", Chat: Chat{ID: 555}}})
+
+	if len(telegraph.recordedCalls()) != 0 {
+		t.Error("Expected no Telegraph calls without a token")
+	}
+	calls := api.callsTo("sendMessage")
+	if len(calls) != 1 || calls[0].Params["text"] != "TELEGRAPH_TOKEN не задан." {
+		t.Errorf("Expected missing token reply, got %v", calls)
+	}
+}
@@ -1,27 +1,31 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 )
 
 func (app *App) registerRoutes() {
 	http.HandleFunc("/bot", app.webhookHandler)
+	http.HandleFunc("GET /r/{id}", app.redirectHandler)
+	http.HandleFunc("GET /metrics", app.metricsHandler)
 }
 
-func (app *App) startServer() {
+func (app *App) startServer() error {
 	slog.Info("Starting webhook server", "port", app.config.Port)
-	err := http.ListenAndServe(":"+app.config.Port, nil)
-	if err != nil {
-		slog.Error("Server error", "error", err)
-		os.Exit(1)
-	}
+	return http.ListenAndServe(":"+app.config.Port, nil)
 }
 
 func (app *App) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Only Telegram knows the secret, anything else could fake an admin's message
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(app.config.WebhookSecret)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		slog.Error("Error reading request body", "error", err)
@@ -34,6 +38,6 @@ func (app *App) webhookHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	app.handleTelegramUpdate(&update)
+	app.updateHandler().HandleUpdate(&update)
 	w.WriteHeader(http.StatusOK)
 }
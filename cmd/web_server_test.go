@@ -10,6 +10,8 @@ import (
 
 func TestWebhookHandler(t *testing.T) {
 	app := &App{
+		telegram: newFakeTelegramApi(t).client(),
+		store:    newMemoryStore(),
 		config: &Config{
 			Token:    "test_token",
 			Port:     "8080",
@@ -111,6 +113,8 @@ func TestWebhookHandler(t *testing.T) {
 
 func TestWebhookHandlerWithNewMember(t *testing.T) {
 	app := &App{
+		telegram: newFakeTelegramApi(t).client(),
+		store:    newMemoryStore(),
 		config: &Config{
 			Token:    "test_token",
 			Port:     "8080",
@@ -357,6 +361,8 @@ func createTestUpdate(chatID int64, hasNewMembers bool) string {
 
 func TestWebhookHandlerWithTestHelper(t *testing.T) {
 	app := &App{
+		telegram: newFakeTelegramApi(t).client(),
+		store:    newMemoryStore(),
 		config: &Config{
 			Token:    "test_token",
 			Port:     "8080",
@@ -395,3 +401,50 @@ func TestWebhookHandlerWithTestHelper(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, rr.Code)
 	}
 }
+
+func TestWebhookHandlerChecksSecret(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{
+			name:           "matching secret",
+			secret:         "s3cret_token",
+			expectedStatus: http.StatusOK,
+			expectedCalls:  1,
+		},
+		{
+			name:           "wrong secret",
+			secret:         "guess",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "no secret",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, api := newTestApp(t, func(config *Config) {
+				config.WebhookSecret = "s3cret_token"
+			})
+			req := httptest.NewRequest("POST", "/bot", bytes.NewBufferString(createTestUpdate(123456789, true)))
+			if tt.secret != "" {
+				req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+			}
+
+			rr := httptest.NewRecorder()
+			app.webhookHandler(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, rr.Code)
+			}
+			if len(api.callsTo("sendMessage")) != tt.expectedCalls {
+				t.Errorf("Expected %d messages, got %d", tt.expectedCalls, len(api.callsTo("sendMessage")))
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+const maxMediaGroupSize = 10
+
+func largestPhotoFileID(photos []PhotoSize) string {
+	if len(photos) == 0 {
+		return ""
+	}
+	// Telegram lists photo sizes from the smallest to the largest
+	return photos[len(photos)-1].FileID
+}
+
+// mediaFileID returns the file_id to reuse for media, it is empty when the file has to be uploaded
+func (app *App) mediaFileID(media WelcomeMedia) string {
+	if media.FileID != "" {
+		return media.FileID
+	}
+	var fileID string
+	app.store.view(func(data *storeData) {
+		fileID = data.MediaFileIDs[media.File]
+	})
+	return fileID
+}
+
+func (app *App) cacheMediaFileID(media WelcomeMedia, photos []PhotoSize) {
+	fileID := largestPhotoFileID(photos)
+	if media.File == "" || fileID == "" {
+		return
+	}
+	err := app.store.update(func(data *storeData) {
+		data.MediaFileIDs[media.File] = fileID
+	})
+	if err != nil {
+		slog.Error("Error caching media file id", "file", media.File, "error", err)
+	}
+}
+
+// forgetMediaFileIDs drops the cached file ids of media and reports whether there were any
+func (app *App) forgetMediaFileIDs(media []WelcomeMedia) bool {
+	var forgotten bool
+	err := app.store.update(func(data *storeData) {
+		for _, item := range media {
+			if _, ok := data.MediaFileIDs[item.File]; ok {
+				delete(data.MediaFileIDs, item.File)
+				forgotten = true
+			}
+		}
+	})
+	if err != nil {
+		slog.Error("Error forgetting media file ids", "error", err)
+	}
+	return forgotten
+}
+
+// isRejectedFileID reports whether Telegram refused a request with a file id, e.g. an expired or a foreign one
+func isRejectedFileID(err error) bool {
+	var apiErr *ApiError
+	return errors.As(err, &apiErr) && apiErr.Code == 400
+}
+
+func openInputFile(path string) (*os.File, InputFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, InputFile{}, err
+	}
+	return file, InputFile{Name: filepath.Base(path), Reader: file}, nil
+}
+
+func (app *App) sendWelcomePhoto(params map[string]any, media WelcomeMedia) error {
+	params["caption"] = params["text"]
+	delete(params, "text")
+
+	if fileID := app.mediaFileID(media); fileID != "" {
+		params["photo"] = fileID
+		err := app.telegram.call("sendPhoto", params, nil)
+		if !isRejectedFileID(err) || !app.forgetMediaFileIDs([]WelcomeMedia{media}) {
+			return err
+		}
+		slog.Warn("Cached file id was rejected, uploading the file again", "file", media.File, "error", err)
+		delete(params, "photo")
+	}
+
+	file, inputFile, err := openInputFile(media.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var message Message
+	if err := app.telegram.callMultipart("sendPhoto", params, map[string]InputFile{"photo": inputFile}, &message); err != nil {
+		return err
+	}
+	app.cacheMediaFileID(media, message.Photo)
+	return nil
+}
+
+func (app *App) sendWelcomeAlbum(params map[string]any, media []WelcomeMedia) error {
+	messages, err := app.sendAlbum(params, media)
+	if isRejectedFileID(err) && app.forgetMediaFileIDs(media) {
+		slog.Warn("Cached file ids were rejected, uploading the album again", "error", err)
+		messages, err = app.sendAlbum(params, media)
+	}
+	if err != nil {
+		return err
+	}
+	for i, item := range media {
+		if i < len(messages) {
+			app.cacheMediaFileID(item, messages[i].Photo)
+		}
+	}
+
+	// Media groups can't carry an inline keyboard, so the welcome text with buttons follows the album
+	_, err = app.telegram.sendMessage(params)
+	return err
+}
+
+func (app *App) sendAlbum(params map[string]any, media []WelcomeMedia) ([]Message, error) {
+	album := map[string]any{
+		"chat_id": params["chat_id"],
+	}
+	if threadID, ok := params["message_thread_id"]; ok {
+		album["message_thread_id"] = threadID
+	}
+
+	items := make([]map[string]string, 0, len(media))
+	files := make(map[string]InputFile)
+	for i, item := range media {
+		fileID := app.mediaFileID(item)
+		if fileID != "" {
+			items = append(items, map[string]string{"type": "photo", "media": fileID})
+			continue
+		}
+
+		file, inputFile, err := openInputFile(item.File)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		field := fmt.Sprintf("photo%d", i)
+		files[field] = inputFile
+		items = append(items, map[string]string{"type": "photo", "media": "attach://" + field})
+	}
+	album["media"] = items
+
+	var messages []Message
+	var err error
+	if len(files) == 0 {
+		err = app.telegram.call("sendMediaGroup", album, &messages)
+	} else {
+		err = app.telegram.callMultipart("sendMediaGroup", album, files, &messages)
+	}
+	return messages, err
+}
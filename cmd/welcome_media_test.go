@@ -0,0 +1,186 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func welcomeMediaTestConfig(media ...WelcomeMedia) func(config *Config) {
+	return func(config *Config) {
+		config.ThreadID = 2
+		config.Welcome = Welcome{Media: media}
+	}
+}
+
+func writeTestImage(t *testing.T, name string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("jpeg"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var testWelcomeMembers = []User{{ID: 111222333, FirstName: "Jane", Username: "janesmith"}}
+
+func TestLargestPhotoFileID(t *testing.T) {
+	tests := []struct {
+		name     string
+		photos   []PhotoSize
+		expected string
+	}{
+		{
+			name:     "no photos",
+			photos:   nil,
+			expected: "",
+		},
+		{
+			name: "several sizes",
+			photos: []PhotoSize{
+				{FileID: "small", Width: 90},
+				{FileID: "large", Width: 1280},
+			},
+			expected: "large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := largestPhotoFileID(tt.photos)
+			if result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSendWelcomePhotoUploadsOnce(t *testing.T) {
+	path := writeTestImage(t, "soap.jpg")
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{File: path}))
+	api.setResponse("sendPhoto", `{"ok": true, "result": {"message_id": 1, "photo": [{"file_id": "small"}, {"file_id": "uploaded"}]}}`)
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("sendPhoto")
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 sendPhoto calls, got %d", len(calls))
+	}
+	if calls[0].Params["photo"] != "file:soap.jpg" {
+		t.Errorf("Expected first photo to be uploaded, got %v", calls[0].Params["photo"])
+	}
+	if !strings.Contains(calls[0].Params["caption"].(string), "Привет, @janesmith!") {
+		t.Errorf("Expected welcome caption, got %v", calls[0].Params["caption"])
+	}
+	if !strings.Contains(calls[0].Params["reply_markup"].(string), "inline_keyboard") {
+		t.Errorf("Expected keyboard, got %v", calls[0].Params["reply_markup"])
+	}
+	if calls[1].Params["photo"] != "uploaded" {
+		t.Errorf("Expected cached file id to be reused, got %v", calls[1].Params["photo"])
+	}
+	if len(api.callsTo("sendMessage")) != 0 {
+		t.Error("Expected no separate text message")
+	}
+}
+
+func TestSendWelcomePhotoWithFileID(t *testing.T) {
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{FileID: "configured"}))
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("sendPhoto")
+	if len(calls) != 1 || calls[0].Params["photo"] != "configured" {
+		t.Fatalf("Expected photo sent by file id, got %+v", calls)
+	}
+	if calls[0].Params["message_thread_id"] != float64(2) {
+		t.Errorf("Expected message_thread_id 2, got %v", calls[0].Params["message_thread_id"])
+	}
+}
+
+func TestSendWelcomePhotoReuploadsRejectedFileID(t *testing.T) {
+	path := writeTestImage(t, "soap.jpg")
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{File: path}))
+	app.store.update(func(data *storeData) {
+		data.MediaFileIDs[path] = "stale"
+	})
+	api.queueResponse("sendPhoto", `{"ok": false, "error_code": 400, "description": "Bad Request: wrong file identifier"}`)
+	api.setResponse("sendPhoto", `{"ok": true, "result": {"message_id": 1, "photo": [{"file_id": "uploaded"}]}}`)
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	calls := api.callsTo("sendPhoto")
+	if len(calls) != 2 || calls[0].Params["photo"] != "stale" || calls[1].Params["photo"] != "file:soap.jpg" {
+		t.Fatalf("Expected the file to be uploaded after the stale file id, got %+v", calls)
+	}
+	if fileID := app.mediaFileID(WelcomeMedia{File: path}); fileID != "uploaded" {
+		t.Errorf("Expected the new file id to be cached, got %q", fileID)
+	}
+}
+
+func TestSendWelcomeAlbumReuploadsRejectedFileIDs(t *testing.T) {
+	path := writeTestImage(t, "soap.jpg")
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{File: path}, WelcomeMedia{FileID: "configured"}))
+	app.store.update(func(data *storeData) {
+		data.MediaFileIDs[path] = "stale"
+	})
+	api.queueResponse("sendMediaGroup", `{"ok": false, "error_code": 400, "description": "Bad Request: wrong file identifier"}`)
+	api.setResponse("sendMediaGroup", `{"ok": true, "result": [{"message_id": 1, "photo": [{"file_id": "uploaded"}]}]}`)
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	albums := api.callsTo("sendMediaGroup")
+	if len(albums) != 2 || albums[1].Params["photo0"] != "file:soap.jpg" {
+		t.Errorf("Expected the album to be uploaded again, got %+v", albums)
+	}
+}
+
+func TestSendWelcomeAlbum(t *testing.T) {
+	path := writeTestImage(t, "soap.jpg")
+	app, api := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{File: path}, WelcomeMedia{FileID: "configured"}))
+	api.setResponse("sendMediaGroup", `{"ok": true, "result": [{"message_id": 1, "photo": [{"file_id": "uploaded"}]}, {"message_id": 2, "photo": [{"file_id": "configured"}]}]}`)
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	albums := api.callsTo("sendMediaGroup")
+	if len(albums) != 2 {
+		t.Fatalf("Expected 2 sendMediaGroup calls, got %d", len(albums))
+	}
+	if albums[0].Params["photo0"] != "file:soap.jpg" {
+		t.Errorf("Expected first album to upload the file, got %v", albums[0].Params)
+	}
+	if !strings.Contains(albums[0].Params["media"].(string), "attach://photo0") {
+		t.Errorf("Expected media to reference the upload, got %v", albums[0].Params["media"])
+	}
+	media := albums[1].Params["media"].([]any)
+	if media[0].(map[string]any)["media"] != "uploaded" {
+		t.Errorf("Expected cached file id in second album, got %v", media[0])
+	}
+
+	if len(api.callsTo("sendMessage")) != 2 {
+		t.Errorf("Expected welcome text with buttons after each album, got %d", len(api.callsTo("sendMessage")))
+	}
+}
+
+func TestSendWelcomePhotoMissingFile(t *testing.T) {
+	app, _ := newTestApp(t, welcomeMediaTestConfig(WelcomeMedia{File: filepath.Join(t.TempDir(), "missing.jpg")}))
+
+	if err := app.sendNewMembersMessage(testWelcomeMembers); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}